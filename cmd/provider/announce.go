@@ -30,8 +30,7 @@ func announceCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	resp, err := doAdminReq(req)
 	if err != nil {
 		return err
 	}
@@ -70,8 +69,7 @@ func announceHttpCommand(cctx *cli.Context) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	resp, err := doAdminReq(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var AnnounceURLCmd = &cli.Command{
+	Name:  "announce-url",
+	Usage: "Manages the indexer URLs to which direct HTTP announcements are sent.",
+	Description: `Adds, removes or lists the indexer URLs to which the provider daemon sends direct HTTP
+announcements. Changes take effect from the next announcement, and are written to the daemon's
+config file.`,
+	Subcommands: []*cli.Command{addAnnounceURLSubCmd, removeAnnounceURLSubCmd, listAnnounceURLsSubCmd},
+}
+
+var addAnnounceURLSubCmd = &cli.Command{
+	Name:   "add",
+	Usage:  "Adds an indexer URL to which direct HTTP announcements are sent.",
+	Flags:  announceURLFlags,
+	Action: doAddAnnounceURL,
+}
+
+var removeAnnounceURLSubCmd = &cli.Command{
+	Name:   "remove",
+	Usage:  "Removes an indexer URL to which direct HTTP announcements are sent.",
+	Flags:  announceURLFlags,
+	Action: doRemoveAnnounceURL,
+}
+
+var listAnnounceURLsSubCmd = &cli.Command{
+	Name:   "list",
+	Usage:  "Lists the indexer URLs to which direct HTTP announcements are sent.",
+	Flags:  listAnnounceURLsFlags,
+	Action: doListAnnounceURLs,
+}
+
+func doAddAnnounceURL(cctx *cli.Context) error {
+	return postAnnounceURLReq(cctx, "/admin/announce/urls/add")
+}
+
+func doRemoveAnnounceURL(cctx *cli.Context) error {
+	return postAnnounceURLReq(cctx, "/admin/announce/urls/remove")
+}
+
+func postAnnounceURLReq(cctx *cli.Context, path string) error {
+	req := adminserver.AnnounceURLReq{
+		URL: announceURLFlagValue,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+path, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	return printAnnounceURLs(cctx, resp)
+}
+
+func doListAnnounceURLs(cctx *cli.Context) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/announce/urls", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	return printAnnounceURLs(cctx, resp)
+}
+
+func printAnnounceURLs(cctx *cli.Context, resp *http.Response) error {
+	var res adminserver.AnnounceURLsRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+
+	var b bytes.Buffer
+	if len(res.URLs) == 0 {
+		b.WriteString("No announce URLs.\n")
+	}
+	for _, u := range res.URLs {
+		b.WriteString(u)
+		b.WriteString("\n")
+	}
+	_, err := cctx.App.Writer.Write(b.Bytes())
+	return err
+}
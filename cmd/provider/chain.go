@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var ChainCmd = &cli.Command{
+	Name:        "chain",
+	Usage:       "Manages the advertisement chain of the provider.",
+	Subcommands: []*cli.Command{compactChainSubCmd},
+}
+
+var compactChainSubCmd = &cli.Command{
+	Name:  "compact",
+	Usage: "Replaces the advertisement chain with one advertisement per currently advertised context ID.",
+	Description: `Publishes a new advertisement chain that contains exactly one advertisement for each
+context ID currently advertised by the provider, omitting the advertisements that were superseded
+or removed. The new chain is announced once it is fully stored, and the previous chain is deleted
+after the grace period has elapsed.`,
+	Flags:  compactChainFlags,
+	Action: doCompactChain,
+}
+
+func doCompactChain(cctx *cli.Context) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodPost, adminAPIFlagValue+"/admin/chain/compact", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Handle failed requests
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.CompactChainRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	msg := fmt.Sprintf("Compacted advertisement chain into %d advertisements with head: %s\n", res.Count, res.AdvId)
+
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+var ContextIDCmd = &cli.Command{
+	Name:        "contextid",
+	Usage:       "Inspects the context IDs advertised by the provider.",
+	Subcommands: []*cli.Command{showContextIDSubCmd},
+}
+
+var showContextIDSubCmd = &cli.Command{
+	Name:  "show",
+	Usage: "Shows the state of a context ID.",
+	Description: `Shows the entries CID and metadata currently advertised for a context ID, whether it is
+removed, and the latest advertisement in the chain for it.
+
+A removed context ID is only shown as long as its removal advertisement remains in the chain.`,
+	Flags:  showContextIDFlags,
+	Action: doShowContextID,
+}
+
+func doShowContextID(cctx *cli.Context) error {
+	if _, err := base64.StdEncoding.DecodeString(contextIDFlagValue); err != nil {
+		return fmt.Errorf("context ID must be base64 encoded: %w", err)
+	}
+	query := url.Values{}
+	query.Set("id", contextIDFlagValue)
+	if optionalProviderIDFlagValue != "" {
+		query.Set("provider", optionalProviderIDFlagValue)
+	}
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/contextid?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.ContextIDStateRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Provider:            %s\n", res.Provider)
+	fmt.Fprintf(&b, "Context ID:          %s\n", base64.StdEncoding.EncodeToString(res.ContextID))
+	fmt.Fprintf(&b, "Is Remove:           %v\n", res.IsRm)
+	if !res.IsRm {
+		fmt.Fprintf(&b, "Entries:             %s\n", res.EntriesCid)
+		fmt.Fprintf(&b, "Metadata:            %s\n", base64.StdEncoding.EncodeToString(res.Metadata))
+		fmt.Fprintf(&b, "Protocols:           %s\n", strings.Join(res.Protocols, ", "))
+	}
+	if res.LastAdvId == cid.Undef {
+		b.WriteString("Last advertisement:  none in chain\n")
+	} else {
+		fmt.Fprintf(&b, "Last advertisement:  %s\n", res.LastAdvId)
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
@@ -4,19 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer/impl"
 	dtnetwork "github.com/filecoin-project/go-data-transfer/network"
 	gstransport "github.com/filecoin-project/go-data-transfer/transport/graphsync"
+	provider "github.com/filecoin-project/index-provider"
 	"github.com/filecoin-project/index-provider/cardatatransfer"
 	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
 	"github.com/filecoin-project/index-provider/engine"
 	"github.com/filecoin-project/index-provider/engine/policy"
+	"github.com/filecoin-project/index-provider/metrics"
 	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
 	"github.com/filecoin-project/index-provider/supplier"
-	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	gsimpl "github.com/ipfs/go-graphsync/impl"
 	gsnet "github.com/ipfs/go-graphsync/network"
 	"github.com/ipfs/go-ipfs/core/bootstrap"
@@ -24,6 +29,7 @@ import (
 	"github.com/ipld/go-car/v2"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
 )
@@ -61,6 +67,9 @@ func daemonCommand(cctx *cli.Context) error {
 		}
 		return fmt.Errorf("cannot load config file: %w", err)
 	}
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 
 	// Initialize libp2p host
 	ctx, cancelp2p := context.WithCancel(cctx.Context)
@@ -70,6 +79,15 @@ func daemonCommand(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	// Keep the keys of previous identities to sign advertisements about their content.
+	prevKeys, err := cfg.Identity.DecodePrevious()
+	if err != nil {
+		return err
+	}
+	signer, err := engine.NewKeySigner(privKey, prevKeys...)
+	if err != nil {
+		return err
+	}
 
 	syncPolicy, err := policy.New(cfg.Ingest.SyncPolicy.Allow, cfg.Ingest.SyncPolicy.Except)
 	if err != nil {
@@ -92,20 +110,19 @@ func daemonCommand(cctx *cli.Context) error {
 	log.Infow("libp2p host initialized", "host_id", h.ID(), "multiaddr", p2pmaddr)
 
 	// Initialize datastore
-	if cfg.Datastore.Type != "levelds" {
-		return fmt.Errorf("only levelds datastore type supported, %q not supported", cfg.Datastore.Type)
-	}
-	dataStorePath, err := config.Path("", cfg.Datastore.Dir)
-	if err != nil {
-		return err
-	}
-	err = checkWritable(dataStorePath)
+	ds, err := newDatastore(cfg.Datastore)
 	if err != nil {
 		return err
 	}
-	ds, err := leveldb.NewDatastore(dataStorePath, nil)
-	if err != nil {
-		return err
+	log.Infow("datastore initialized", "type", cfg.Datastore.Type)
+
+	var cacheDs datastore.Batching
+	if cfg.Ingest.LinkCacheDatastore != nil {
+		cacheDs, err = newLinkCacheDatastore(*cfg.Ingest.LinkCacheDatastore)
+		if err != nil {
+			return fmt.Errorf("cannot initialize link cache datastore: %w", err)
+		}
+		log.Infow("link cache datastore initialized", "type", cfg.Ingest.LinkCacheDatastore.Type)
 	}
 
 	gsnet := gsnet.NewFromLibp2pHost(h)
@@ -122,16 +139,48 @@ func daemonCommand(cctx *cli.Context) error {
 	}
 
 	// Starting provider core
-	eng, err := engine.New(
+	engOpts := []engine.Option{
 		engine.WithDatastore(ds),
 		engine.WithDataTransfer(dt),
 		engine.WithDirectAnnounce(cfg.DirectAnnounce.URLs...),
 		engine.WithHost(h),
 		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
-		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
+		engine.WithPurgeCacheOnStart(cfg.Ingest.PurgeLinkCache),
+		engine.WithConsistencyCheck(cfg.Ingest.CheckMappings),
+		engine.WithConsistencyRepair(cfg.Ingest.RepairMappings),
 		engine.WithTopicName(cfg.Ingest.PubSubTopic),
 		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)),
-		engine.WithSyncPolicy(syncPolicy))
+		engine.WithSyncPolicy(syncPolicy),
+		engine.WithSigner(signer),
+	}
+	switch cfg.Ingest.EntriesFormat {
+	case config.HamtEntriesFormat:
+		hashAlg, err := cfg.Ingest.Hamt.HashAlg()
+		if err != nil {
+			return err
+		}
+		engOpts = append(engOpts, engine.WithHamtEntries(hashAlg, cfg.Ingest.Hamt.BitWidth, cfg.Ingest.Hamt.BucketSize))
+	default:
+		engOpts = append(engOpts, engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize))
+	}
+	retAddrs, err := cfg.ProviderServer.RetrievalAddrs()
+	if err != nil {
+		return err
+	}
+	if len(retAddrs) != 0 {
+		engOpts = append(engOpts, engine.WithRetrievalAddrs(retAddrs...))
+	}
+	if cacheDs != nil {
+		engOpts = append(engOpts, engine.WithEntriesCacheDatastore(cacheDs))
+	}
+	if cfg.Ingest.PublisherKind == config.HttpPublisherKind {
+		httpPubAddr, err := cfg.Ingest.HttpPublisher.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		engOpts = append(engOpts, engine.WithHttpPublisherListenAddr(httpPubAddr))
+	}
+	eng, err := engine.New(engOpts...)
 	if err != nil {
 		return err
 	}
@@ -141,6 +190,25 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
+	// Advertise the configured retrieval addresses if they differ from the ones last advertised.
+	// Nothing is advertised yet if there are no advertisements, since the first advertisement
+	// carries the addresses anyway.
+	latestAdCid, _, err := eng.GetLatestAdv(ctx)
+	if err != nil {
+		return err
+	}
+	if len(retAddrs) != 0 && latestAdCid != cid.Undef {
+		adCid, err := eng.UpdateProviderAddrs(ctx, peer.AddrInfo{ID: h.ID(), Addrs: retAddrs})
+		switch err {
+		case nil:
+			log.Infow("Advertised change of retrieval addresses", "adCid", adCid, "addrs", cfg.ProviderServer.RetrievalMultiaddrs)
+		case provider.ErrAlreadyAdvertised:
+			log.Debug("Retrieval addresses unchanged since last advertised")
+		default:
+			return fmt.Errorf("failed to advertise retrieval addresses: %w", err)
+		}
+	}
+
 	// Instantiate CAR supplier and register it as the multihash lister onto the engine.
 	cs := supplier.NewCarSupplier(eng, ds, car.ZeroLengthSectionAsEOF(carZeroLengthAsEOFFlagValue))
 
@@ -150,12 +218,21 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
+	// cfgLk serializes the changes made to the config at runtime via the admin server, which are
+	// persisted to the config file.
+	var cfgLk sync.Mutex
+
 	// TODO: unclear why the admin config takes multiaddr if it is always converted to net addr; simplify.
 	addr, err := cfg.AdminServer.ListenNetAddr()
 	if err != nil {
 		return err
 	}
 
+	adminTLS, err := cfg.AdminServer.TLSConfig()
+	if err != nil {
+		return err
+	}
+
 	adminSvr, err := adminserver.New(
 		h,
 		eng,
@@ -163,6 +240,24 @@ func daemonCommand(cctx *cli.Context) error {
 		adminserver.WithListenAddr(addr),
 		adminserver.WithReadTimeout(time.Duration(cfg.AdminServer.ReadTimeout)),
 		adminserver.WithWriteTimeout(time.Duration(cfg.AdminServer.WriteTimeout)),
+		adminserver.WithBearerToken(cfg.AdminServer.BearerToken),
+		adminserver.WithTLS(adminTLS),
+		adminserver.WithOnAnnounceURLsChange(func(urls []*url.URL) error {
+			cfgLk.Lock()
+			defer cfgLk.Unlock()
+			cfg.DirectAnnounce.URLs = make([]string, 0, len(urls))
+			for _, u := range urls {
+				cfg.DirectAnnounce.URLs = append(cfg.DirectAnnounce.URLs, u.String())
+			}
+			return cfg.Save("")
+		}),
+		adminserver.WithOnSyncPolicyChange(func(allow bool, except []string) error {
+			cfgLk.Lock()
+			defer cfgLk.Unlock()
+			cfg.Ingest.SyncPolicy.Allow = allow
+			cfg.Ingest.SyncPolicy.Except = except
+			return cfg.Save("")
+		}),
 	)
 
 	if err != nil {
@@ -170,11 +265,30 @@ func daemonCommand(cctx *cli.Context) error {
 	}
 	log.Infow("admin server initialized", "address", cfg.AdminServer.ListenMultiaddr)
 
-	errChan := make(chan error, 1)
+	// An empty metrics listen address disables the metrics server.
+	var metricsSvr *metrics.Server
+	if cfg.Metrics.ListenMultiaddr != "" {
+		metricsAddr, err := cfg.Metrics.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		metricsSvr, err = metrics.NewServer(metricsAddr)
+		if err != nil {
+			return err
+		}
+		log.Infow("metrics server initialized", "address", cfg.Metrics.ListenMultiaddr)
+	}
+
+	errChan := make(chan error, 2)
 	fmt.Fprintf(cctx.App.ErrWriter, "Starting admin server on %s ...", cfg.AdminServer.ListenMultiaddr)
 	go func() {
 		errChan <- adminSvr.Start()
 	}()
+	if metricsSvr != nil {
+		go func() {
+			errChan <- metricsSvr.Start()
+		}()
+	}
 
 	// If there are bootstrap peers and bootstrapping is enabled, then try to
 	// connect to the minimum set of peers.
@@ -226,6 +340,13 @@ func daemonCommand(cctx *cli.Context) error {
 		finalErr = ErrDaemonStop
 	}
 
+	if cacheDs != nil {
+		if err = cacheDs.Close(); err != nil {
+			log.Errorf("Error closing link cache datastore: %s", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
 	// cancel libp2p server
 	cancelp2p()
 
@@ -233,6 +354,12 @@ func daemonCommand(cctx *cli.Context) error {
 		log.Errorw("Error shutting down admin server: %s", err)
 		finalErr = ErrDaemonStop
 	}
+	if metricsSvr != nil {
+		if err = metricsSvr.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down metrics server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 	log.Infow("node stopped")
 	return finalErr
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/index-provider/cmd/provider/internal"
+	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/urfave/cli/v2"
+)
+
+// migrateBatchSize is the number of entries copied per batch during datastore migration.
+const migrateBatchSize = 1024
+
+// linkCacheKey is the namespace under which the engine caches entry chunks in its datastore.
+var linkCacheKey = datastore.NewKey("/cache/links")
+
+var DatastoreCmd = &cli.Command{
+	Name:        "datastore",
+	Aliases:     []string{"ds"},
+	Usage:       "Manages the datastore of the provider.",
+	Subcommands: []*cli.Command{migrateDatastoreSubCmd},
+}
+
+var migrateDatastoreSubCmd = &cli.Command{
+	Name:  "migrate",
+	Usage: "Copies the content of the configured datastore to a new datastore of a different type.",
+	Description: `Copies every entry in the datastore configured in the provider config file to a new
+datastore of the given type and directory, then updates the config file to use the new datastore.
+
+The daemon must not be running during migration. The destination directory must not contain an
+existing datastore, and the source datastore is left untouched; it may be deleted once the daemon
+is confirmed to work with the new datastore.`,
+	Flags:  migrateDatastoreFlags,
+	Action: doMigrateDatastore,
+}
+
+// newDatastore instantiates the datastore configured by the given config, creating its directory
+// under the config root if needed.
+func newDatastore(cfg config.Datastore) (datastore.Batching, error) {
+	if cfg.Type == config.MemoryDatastoreType {
+		return internal.NewDatastore(cfg, "")
+	}
+	dir, err := config.Path("", cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkWritable(dir); err != nil {
+		return nil, err
+	}
+	return internal.NewDatastore(cfg, dir)
+}
+
+// newLinkCacheDatastore instantiates the separate link cache datastore configured by the given
+// config. The datastore is wrapped in the namespace under which the engine caches entry chunks in
+// its main datastore, so that datastore types that store blocks separately, e.g. flatfs, store the
+// cached chunks as blocks.
+func newLinkCacheDatastore(cfg config.Datastore) (datastore.Batching, error) {
+	ds, err := newDatastore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return namespace.Wrap(ds, linkCacheKey), nil
+}
+
+func doMigrateDatastore(cctx *cli.Context) error {
+	cfg, err := config.Load("")
+	if err != nil {
+		if err == config.ErrNotInitialized {
+			return errors.New("reference provider is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return fmt.Errorf("cannot load config file: %w", err)
+	}
+
+	to := cfg.Datastore
+	to.Type = dsTypeFlagValue
+	to.Dir = cctx.String(dsDirFlag.Name)
+	if to.Dir == "" {
+		to.Dir = "datastore-" + to.Type
+	}
+	if err := to.Validate(); err != nil {
+		return err
+	}
+	if cfg.Datastore.Type == config.MemoryDatastoreType || to.Type == config.MemoryDatastoreType {
+		return errors.New("cannot migrate from or to memory datastore")
+	}
+	if to.Type == cfg.Datastore.Type && to.Dir == cfg.Datastore.Dir {
+		return errors.New("source and destination datastores are the same")
+	}
+
+	src, err := newDatastore(cfg.Datastore)
+	if err != nil {
+		return fmt.Errorf("cannot open source datastore: %w", err)
+	}
+	defer src.Close()
+	dst, err := newDatastore(to)
+	if err != nil {
+		return fmt.Errorf("cannot open destination datastore: %w", err)
+	}
+	defer dst.Close()
+
+	copied, err := copyDatastore(cctx, src, dst)
+	if err != nil {
+		return err
+	}
+	if err = dst.Sync(cctx.Context, datastore.NewKey("/")); err != nil {
+		return err
+	}
+
+	cfg.Datastore = to
+	if err = cfg.Save(""); err != nil {
+		return fmt.Errorf("migrated datastore but failed to update config: %w", err)
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Migrated %d entries to %s datastore at %s\n", copied, to.Type, to.Dir)
+	return err
+}
+
+func copyDatastore(cctx *cli.Context, src, dst datastore.Batching) (int, error) {
+	ctx := cctx.Context
+	results, err := dst.Query(ctx, query.Query{KeysOnly: true, Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	existing, err := results.Rest()
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) != 0 {
+		return 0, errors.New("destination datastore is not empty")
+	}
+
+	results, err = src.Query(ctx, query.Query{})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	batch, err := dst.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var copied int
+	for r := range results.Next() {
+		if r.Error != nil {
+			return copied, fmt.Errorf("cannot read source datastore: %w", r.Error)
+		}
+		if err = batch.Put(ctx, datastore.RawKey(r.Key), r.Value); err != nil {
+			return copied, err
+		}
+		copied++
+		if copied%migrateBatchSize == 0 {
+			if err = batch.Commit(ctx); err != nil {
+				return copied, err
+			}
+			if batch, err = dst.Batch(ctx); err != nil {
+				return copied, err
+			}
+			log.Infow("Migrating datastore", "copied", copied)
+		}
+	}
+	return copied, batch.Commit(ctx)
+}
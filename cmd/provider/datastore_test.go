@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLinkCacheDatastore_StoresChunksAsFlatFSBlocks(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	t.Setenv(config.EnvDir, root)
+
+	cfg := config.NewDatastore()
+	cfg.Type = config.FlatFSDatastoreType
+	cfg.Dir = "linkcache"
+	subject, err := newLinkCacheDatastore(cfg)
+	require.NoError(t, err)
+
+	// The engine caches chunks in a separate datastore under un-namespaced CID keys.
+	chunkKey := datastore.NewKey("bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+	require.NoError(t, subject.Put(ctx, chunkKey, []byte("chunk")))
+	got, err := subject.Get(ctx, chunkKey)
+	require.NoError(t, err)
+	require.Equal(t, []byte("chunk"), got)
+	require.NoError(t, subject.Close())
+
+	blocks, err := flatfs.Open(filepath.Join(root, cfg.Dir, "blocks"), false)
+	require.NoError(t, err)
+	defer blocks.Close()
+	has, err := blocks.Has(ctx, datastore.NewKey("BAFKREIGH2AKISCAILDCQABSYG3DFR6CHU3FGPREGIYMSCK7E7AQA4S52ZY"))
+	require.NoError(t, err)
+	require.True(t, has)
+
+	meta, err := leveldb.NewDatastore(filepath.Join(root, cfg.Dir, "meta"), nil)
+	require.NoError(t, err)
+	defer meta.Close()
+	results, err := meta.Query(ctx, query.Query{KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := results.Rest()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var ExportChainCmd = &cli.Command{
+	Name:  "export-chain",
+	Usage: "Exports the advertisement chain of the provider as a CARv2 file.",
+	Description: `Writes every advertisement in the chain, from the latest advertisement, to a new CARv2 file
+with the latest advertisement as its root. If --with-entries is set, the entries of the context IDs
+currently advertised are written too, regenerating the ones that are not cached.
+
+The file is written by the daemon, and so the path must be accessible to it. The exported chain can
+be imported into a provider with a fresh datastore via the "import-chain" command, e.g. when moving
+the provider identity to new hardware.`,
+	Flags:  exportChainFlags,
+	Action: doExportChain,
+}
+
+func doExportChain(cctx *cli.Context) error {
+	absOut, err := filepath.Abs(chainOutFlagValue)
+	if err != nil {
+		return err
+	}
+	req := adminserver.ExportChainReq{
+		Out:         absOut,
+		WithEntries: withEntriesFlagValue,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/chain/export", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Handle failed requests
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.ChainCarRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	msg := fmt.Sprintf("Exported %d advertisements with head %s to %s\n", res.Count, res.AdvId, absOut)
+
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
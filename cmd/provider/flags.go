@@ -4,14 +4,66 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-var announceFlags = []cli.Flag{
-	adminAPIFlag,
-}
+var announceFlags = adminAPIFlags
 
-var announceHttpFlags = []cli.Flag{
-	adminAPIFlag,
+var announceHttpFlags = append([]cli.Flag{
 	indexerFlag,
-}
+}, adminAPIFlags...)
+
+var announceURLFlags = append([]cli.Flag{
+	announceURLFlag,
+}, adminAPIFlags...)
+
+var listAnnounceURLsFlags = adminAPIFlags
+
+var (
+	announceURLFlagValue string
+	announceURLFlag      = &cli.StringFlag{
+		Name:        "url",
+		Usage:       "The indexer URL to which direct HTTP announcements are sent, e.g. `http://indexer.example.com:3001`.",
+		Aliases:     []string{"u"},
+		Required:    true,
+		Destination: &announceURLFlagValue,
+	}
+)
+
+var compactChainFlags = adminAPIFlags
+
+var verifyChainFlags = adminAPIFlags
+
+var exportChainFlags = append([]cli.Flag{
+	chainOutFlag,
+	withEntriesFlag,
+}, adminAPIFlags...)
+
+var importChainFlags = append([]cli.Flag{
+	chainInFlag,
+}, adminAPIFlags...)
+
+var (
+	chainOutFlagValue string
+	chainOutFlag      = &cli.StringFlag{
+		Name:        "out",
+		Aliases:     []string{"o"},
+		Usage:       "Path at which to write the CAR file. The file must not already exist.",
+		Destination: &chainOutFlagValue,
+		Required:    true,
+	}
+	withEntriesFlagValue bool
+	withEntriesFlag      = &cli.BoolFlag{
+		Name:        "with-entries",
+		Usage:       "Whether to include the entries of the context IDs currently advertised.",
+		Destination: &withEntriesFlagValue,
+	}
+	chainInFlagValue string
+	chainInFlag      = &cli.StringFlag{
+		Name:        "in",
+		Aliases:     []string{"i"},
+		Usage:       "Path to the CAR file to import.",
+		Destination: &chainInFlagValue,
+		Required:    true,
+	}
+)
 
 var daemonFlags = []cli.Flag{
 	carZeroLengthAsEOFFlag,
@@ -24,17 +76,44 @@ var daemonFlags = []cli.Flag{
 	},
 }
 
-var initFlags = []cli.Flag{}
+var initFlags = []cli.Flag{
+	entriesFormatFlag,
+	hamtHashFuncFlag,
+	hamtBitWidthFlag,
+	hamtBucketSizeFlag,
+}
+
+var (
+	entriesFormatFlag = &cli.StringFlag{
+		Name:  "entries-format",
+		Usage: "The format of advertisement entries; either `chain` or `hamt`.",
+		Value: "chain",
+	}
+	hamtHashFuncFlag = &cli.StringFlag{
+		Name:  "hamt-hash-func",
+		Usage: "The hash function used by HAMT entries; one of `identity`, `sha2-256` or `murmur3-x64-64`.",
+		Value: "murmur3-x64-64",
+	}
+	hamtBitWidthFlag = &cli.IntFlag{
+		Name:  "hamt-bit-width",
+		Usage: "The bit-width used by HAMT entries; must be at least 3.",
+		Value: 5,
+	}
+	hamtBucketSizeFlag = &cli.IntFlag{
+		Name:  "hamt-bucket-size",
+		Usage: "The bucket size used by HAMT entries; must be at least 1.",
+		Value: 3,
+	}
+)
 
-var connectFlags = []cli.Flag{
+var connectFlags = append([]cli.Flag{
 	&cli.StringFlag{
 		Name:     "indexermaddr",
 		Usage:    "Indexer multiaddr to connect",
 		Aliases:  []string{"imaddr"},
 		Required: true,
 	},
-	adminAPIFlag,
-}
+}, adminAPIFlags...)
 
 var indexerFlag = &cli.StringFlag{
 	Name:     "indexer",
@@ -90,19 +169,111 @@ var registerFlags = []cli.Flag{
 	addrFlag,
 }
 
-var importCarFlags = []cli.Flag{
-	adminAPIFlag,
+var importCarFlags = append([]cli.Flag{
 	carPathFlag,
 	metadataFlag,
 	keyFlag,
-}
+}, adminAPIFlags...)
 
-var removeCarFlags = []cli.Flag{
-	adminAPIFlag,
+var removeCarFlags = append([]cli.Flag{
 	optionalCarPathFlag,
 	keyFlag,
+}, adminAPIFlags...)
+
+var removeProviderFlags = append([]cli.Flag{
+	providerIDFlag,
+}, adminAPIFlags...)
+
+var (
+	providerIDFlagValue string
+	providerIDFlag      = &cli.StringFlag{
+		Name:        "provider-id",
+		Usage:       "The peer ID of the provider.",
+		Aliases:     []string{"p"},
+		Required:    true,
+		Destination: &providerIDFlagValue,
+	}
+)
+
+var showContextIDFlags = append([]cli.Flag{
+	contextIDFlag,
+	optionalProviderIDFlag,
+}, adminAPIFlags...)
+
+var (
+	contextIDFlagValue string
+	contextIDFlag      = &cli.StringFlag{
+		Name:        "context-id",
+		Usage:       "Base64 encoded context ID.",
+		Aliases:     []string{"c"},
+		Required:    true,
+		Destination: &contextIDFlagValue,
+	}
+	optionalProviderIDFlagValue string
+	optionalProviderIDFlag      = &cli.StringFlag{
+		Name:        "provider-id",
+		Usage:       "The peer ID of the provider.",
+		Aliases:     []string{"p"},
+		DefaultText: "The default provider of the daemon",
+		Destination: &optionalProviderIDFlagValue,
+	}
+)
+
+var migrateDatastoreFlags = []cli.Flag{
+	dsTypeFlag,
+	dsDirFlag,
 }
 
+var (
+	dsTypeFlagValue string
+	dsTypeFlag      = &cli.StringFlag{
+		Name:        "type",
+		Usage:       "The type of datastore to migrate to; one of levelds, badger or flatfs.",
+		Aliases:     []string{"t"},
+		Required:    true,
+		Destination: &dsTypeFlagValue,
+	}
+	dsDirFlag = &cli.StringFlag{
+		Name:        "dir",
+		Usage:       "The directory within the config root at which to create the new datastore.",
+		Aliases:     []string{"d"},
+		DefaultText: "datastore-<type>",
+	}
+)
+
+var policyPeerFlags = append([]cli.Flag{
+	policyPeerFlag,
+}, adminAPIFlags...)
+
+var listPolicyFlags = adminAPIFlags
+
+var (
+	policyPeerFlagValue string
+	policyPeerFlag      = &cli.StringFlag{
+		Name:        "peer",
+		Usage:       "The peer ID of the indexer.",
+		Aliases:     []string{"p"},
+		Required:    true,
+		Destination: &policyPeerFlagValue,
+	}
+)
+
+var statusFlags = adminAPIFlags
+
+var gcFlags = append([]cli.Flag{
+	dryRunFlag,
+}, adminAPIFlags...)
+
+var (
+	dryRunFlagValue bool
+	dryRunFlag      = &cli.BoolFlag{
+		Name:        "dry-run",
+		Usage:       "Only report the garbage that would be collected without deleting it.",
+		Aliases:     []string{"n"},
+		Destination: &dryRunFlagValue,
+	}
+)
+
 var (
 	metadataFlagValue string
 	metadataFlag      = &cli.StringFlag{
@@ -146,6 +317,16 @@ var (
 	}
 )
 
+// adminAPIFlags are the flags of commands that interact with the admin server, which set its
+// address and the credentials with which to authenticate to it.
+var adminAPIFlags = []cli.Flag{
+	adminAPIFlag,
+	adminTokenFlag,
+	adminCACertFlag,
+	adminCertFlag,
+	adminKeyFlag,
+}
+
 var (
 	adminAPIFlagValue string
 	adminAPIFlag      = &cli.StringFlag{
@@ -180,3 +361,35 @@ var (
 		Destination: &adEntriesRecurLimitFlagValue,
 	}
 )
+
+var (
+	adminTokenFlagValue string
+	adminTokenFlag      = &cli.StringFlag{
+		Name:        "admin-token",
+		Usage:       "The bearer token with which to authenticate to the admin server.",
+		EnvVars:     []string{"PROVIDER_ADMIN_TOKEN"},
+		Destination: &adminTokenFlagValue,
+	}
+	adminCACertFlagValue string
+	adminCACertFlag      = &cli.PathFlag{
+		Name:        "admin-ca-cert",
+		Usage:       "Path to the PEM encoded CA certificate with which to verify the admin server certificate.",
+		EnvVars:     []string{"PROVIDER_ADMIN_CA_CERT"},
+		DefaultText: "System root CAs",
+		Destination: &adminCACertFlagValue,
+	}
+	adminCertFlagValue string
+	adminCertFlag      = &cli.PathFlag{
+		Name:        "admin-cert",
+		Usage:       "Path to the PEM encoded client certificate with which to authenticate to the admin server over mutual TLS.",
+		EnvVars:     []string{"PROVIDER_ADMIN_CERT"},
+		Destination: &adminCertFlagValue,
+	}
+	adminKeyFlagValue string
+	adminKeyFlag      = &cli.PathFlag{
+		Name:        "admin-key",
+		Usage:       "Path to the PEM encoded private key of the client certificate.",
+		EnvVars:     []string{"PROVIDER_ADMIN_KEY"},
+		Destination: &adminKeyFlagValue,
+	}
+)
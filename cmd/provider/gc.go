@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var GCCmd = &cli.Command{
+	Name:  "gc",
+	Usage: "Deletes advertisements and cached entries that are no longer reachable.",
+	Description: `Deletes the advertisements that are not reachable from the latest advertisement, and the
+cached entries of context IDs that are no longer advertised. Advertisements replaced by chain
+compaction are retained until their grace period has elapsed.
+
+Publishing advertisements is paused while garbage is collected.`,
+	Flags:  gcFlags,
+	Action: doGC,
+}
+
+func doGC(cctx *cli.Context) error {
+	req := adminserver.GCReq{
+		DryRun: dryRunFlagValue,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/gc", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.GCRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	verb := "Deleted"
+	if res.DryRun {
+		verb = "Would delete"
+	}
+	msg := fmt.Sprintf("%s %d advertisements and %d cached entries.\n\tLive advertisements: %d\n\tLive entries: %d\n",
+		verb, res.SweptAds, res.SweptEntries, res.LiveAds, res.LiveEntries)
+
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
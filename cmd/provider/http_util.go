@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -24,8 +26,44 @@ func doHttpPostReq(ctx context.Context, path string, req interface{}) (resp *htt
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	cl := &http.Client{}
-	return cl.Do(httpReq)
+	return doAdminReq(httpReq)
+}
+
+// doAdminReq sends the given request to the admin server, authenticating with the bearer token
+// and client certificate specified by the admin flags, if any. The admin server certificate is
+// verified against the CA certificate specified by the admin flags, or the system root CAs if
+// none is specified.
+//
+// This function is intended for internal use in CLI to interact with the admin server.
+func doAdminReq(req *http.Request) (*http.Response, error) {
+	if adminTokenFlagValue != "" {
+		req.Header.Set("Authorization", "Bearer "+adminTokenFlagValue)
+	}
+	tlsCfg := &tls.Config{}
+	if adminCACertFlagValue != "" {
+		pem, err := ioutil.ReadFile(adminCACertFlagValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin CA certificate: %w", err)
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in admin CA certificate file %s", adminCACertFlagValue)
+		}
+	}
+	if adminCertFlagValue != "" || adminKeyFlagValue != "" {
+		cert, err := tls.LoadX509KeyPair(adminCertFlagValue, adminKeyFlagValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load admin client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	cl := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsCfg,
+		},
+	}
+	return cl.Do(req)
 }
 
 // errFromHttpResp constructs an error from a HTTP response.
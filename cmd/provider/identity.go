@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+var IdentityCmd = &cli.Command{
+	Name:        "identity",
+	Usage:       "Manages the identity of the provider.",
+	Subcommands: []*cli.Command{rotateIdentitySubCmd},
+}
+
+var rotateIdentitySubCmd = &cli.Command{
+	Name:  "rotate",
+	Usage: "Generates a new identity for the provider and retains the current one as previous.",
+	Description: `Generates a new key pair and replaces the identity in the provider config file with it.
+The current identity is kept in the config file as a previous identity, along with any earlier ones.
+The config file is updated atomically.
+
+The daemon must not be running during rotation. Once restarted, the daemon hands over to the new
+identity by publishing an advertisement with no entries on top of the existing advertisement chain,
+carrying the new provider ID and addresses, signed by the new key.
+
+Advertisements cannot transfer content from one provider ID to another, so content advertised under
+a previous identity remains attributed to it by indexers. The daemon keeps signing advertisements
+about such content with the key of the previous identity, e.g. to remove it by running
+"provider remove provider" with the previous provider ID.`,
+	Action: doRotateIdentity,
+}
+
+func doRotateIdentity(cctx *cli.Context) error {
+	cfg, err := config.Load("")
+	if err != nil {
+		if err == config.ErrNotInitialized {
+			return errors.New("reference provider is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return fmt.Errorf("cannot load config file: %w", err)
+	}
+	prev := cfg.Identity
+
+	next, err := config.CreateIdentity(cctx.App.Writer)
+	if err != nil {
+		return err
+	}
+	cfg.Identity = prev.Rotate(next)
+	if err = cfg.Save(""); err != nil {
+		return fmt.Errorf("cannot update config file: %w", err)
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Rotated identity from %s to %s\nRestart the daemon to hand over to the new identity.\n",
+		prev.PeerID, next.PeerID)
+	return err
+}
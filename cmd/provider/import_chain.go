@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var ImportChainCmd = &cli.Command{
+	Name:  "import-chain",
+	Usage: "Seeds a provider with no advertisements with the chain in a CARv2 file.",
+	Description: `Imports the advertisement chain from a CARv2 file written by the "export-chain" command, and
+marks its root as the latest advertisement. The mappings of the context IDs advertised by the chain
+are restored, so that they can be updated or removed as usual, and the latest advertisement is
+announced. Any entries in the file are skipped; entries are regenerated from the imported content
+sources as needed.
+
+The provider must not have published any advertisements, and must use the identity that signed the
+imported chain in order to publish on top of it; a chain whose latest advertisement was signed by a
+different identity is rejected. Publishing is paused until the import completes. The file is read
+by the daemon, and so the path must be accessible to it.`,
+	Flags:  importChainFlags,
+	Action: doImportChain,
+}
+
+func doImportChain(cctx *cli.Context) error {
+	absIn, err := filepath.Abs(chainInFlagValue)
+	if err != nil {
+		return err
+	}
+	req := adminserver.ImportChainReq{
+		In: absIn,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/chain/import", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Handle failed requests
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.ChainCarRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	msg := fmt.Sprintf("Imported %d advertisements with head %s\n", res.Count, res.AdvId)
+
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
 	"github.com/urfave/cli/v2"
 )
@@ -40,7 +42,24 @@ func initCommand(cctx *cli.Context) error {
 	}
 
 	// Use values from flags to override defaults
-	// cfg.Identity = struct{}{}
+	if err = setEntriesFormat(cctx, &cfg.Ingest); err != nil {
+		return err
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
 
 	return cfg.Save(configFile)
 }
+
+func setEntriesFormat(cctx *cli.Context, ingest *config.Ingest) error {
+	ingest.EntriesFormat = config.EntriesFormat(cctx.String(entriesFormatFlag.Name))
+	hamtFlagSet := cctx.IsSet(hamtHashFuncFlag.Name) || cctx.IsSet(hamtBitWidthFlag.Name) || cctx.IsSet(hamtBucketSizeFlag.Name)
+	if hamtFlagSet && ingest.EntriesFormat != config.HamtEntriesFormat {
+		return fmt.Errorf("hamt flags can only be set when entries format is %s", config.HamtEntriesFormat)
+	}
+	ingest.Hamt.HashFunc = cctx.String(hamtHashFuncFlag.Name)
+	ingest.Hamt.BitWidth = cctx.Int(hamtBitWidthFlag.Name)
+	ingest.Hamt.BucketSize = cctx.Int(hamtBucketSizeFlag.Name)
+	return nil
+}
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/multiformats/go-multiaddr"
@@ -18,6 +23,24 @@ type AdminServer struct {
 	ListenMultiaddr string
 	ReadTimeout     Duration
 	WriteTimeout    Duration
+	// BearerToken is the token that requests to the admin server must present in their
+	// Authorization header as "Bearer <token>". Requests are not authenticated if empty.
+	BearerToken string `json:",omitempty"`
+	// TLS configures serving the admin server over TLS. The admin server is served over plain HTTP
+	// if nil.
+	TLS *AdminTLS `json:",omitempty"`
+}
+
+// AdminTLS configures TLS, and optionally mutual TLS, for the admin server.
+type AdminTLS struct {
+	// CertFile is the path to the PEM encoded certificate of the admin server.
+	CertFile string
+	// KeyFile is the path to the PEM encoded private key of the admin server.
+	KeyFile string
+	// ClientCAFile is the path to the PEM encoded certificates of the authorities that sign client
+	// certificates. If set, clients must present a certificate signed by one of them, i.e. mutual
+	// TLS is required.
+	ClientCAFile string `json:",omitempty"`
 }
 
 // NewAdminServer instantiates a new AdminServer config with default values.
@@ -42,6 +65,46 @@ func (as *AdminServer) ListenNetAddr() (string, error) {
 	return netAddr.String(), nil
 }
 
+// Validate checks that the TLS config is well-formed.
+func (t *AdminTLS) Validate() error {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("both certificate and key files must be set")
+	}
+	return nil
+}
+
+// TLSConfig loads the certificates configured by AdminServer.TLS, or returns nil if TLS is not
+// configured.
+func (as *AdminServer) TLSConfig() (*tls.Config, error) {
+	if as.TLS == nil {
+		return nil, nil
+	}
+	if err := as.TLS.Validate(); err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(as.TLS.CertFile, as.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load admin server certificate: %w", err)
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if as.TLS.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(as.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read admin client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in admin client CA file %s", as.TLS.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
 // PopulateDefaults replaces zero-values in the config with default values.
 func (c *AdminServer) PopulateDefaults() {
 	if c.ListenMultiaddr == "" {
@@ -3,10 +3,12 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/multiformats/go-multiaddr"
 )
 
 // Config is used to load config files.
@@ -18,6 +20,7 @@ type Config struct {
 	AdminServer    AdminServer
 	Bootstrap      Bootstrap
 	DirectAnnounce DirectAnnounce
+	Metrics        Metrics
 }
 
 const (
@@ -101,6 +104,7 @@ func Load(filePath string) (*Config, error) {
 		AdminServer:    NewAdminServer(),
 		ProviderServer: NewProviderServer(),
 		DirectAnnounce: NewDirectAnnounce(),
+		Metrics:        NewMetrics(),
 	}
 
 	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
@@ -128,18 +132,30 @@ func (c *Config) Save(filePath string) error {
 		return err
 	}
 
-	f, err := os.Create(filePath)
+	buf, err := Marshal(c)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	buf, err := Marshal(c)
+	// Write to a temporary file in the same directory first and then rename it, so that the config
+	// file is replaced atomically and is never left partially written.
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp*")
 	if err != nil {
 		return err
 	}
-	_, err = f.Write(buf)
-	return err
+	defer os.Remove(f.Name())
+	if _, err = f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filePath)
 }
 
 // String returns a pretty-printed json config.
@@ -157,3 +173,72 @@ func (c *Config) PopulateDefaults() {
 	c.Ingest.PopulateDefaults()
 	c.ProviderServer.PopulateDefaults()
 }
+
+// Validate checks that the config is well-formed and that its sections are consistent with one
+// another.
+func (c *Config) Validate() error {
+	if err := c.Datastore.Validate(); err != nil {
+		return err
+	}
+	if lcds := c.Ingest.LinkCacheDatastore; lcds != nil {
+		if err := lcds.Validate(); err != nil {
+			return fmt.Errorf("bad link cache datastore: %w", err)
+		}
+		if lcds.Type != MemoryDatastoreType && c.Datastore.Type != MemoryDatastoreType &&
+			filepath.Clean(lcds.Dir) == filepath.Clean(c.Datastore.Dir) {
+			return fmt.Errorf("link cache datastore and main datastore cannot share the same directory: %s", lcds.Dir)
+		}
+	}
+	if _, err := c.ProviderServer.RetrievalAddrs(); err != nil {
+		return err
+	}
+	if _, err := multiaddr.NewMultiaddr(c.ProviderServer.ListenMultiaddr); err != nil {
+		return fmt.Errorf("bad provider server listen address %s: %s", c.ProviderServer.ListenMultiaddr, err)
+	}
+	adminAddr, err := c.AdminServer.ListenNetAddr()
+	if err != nil {
+		return fmt.Errorf("bad admin server listen address %s: %s", c.AdminServer.ListenMultiaddr, err)
+	}
+	if c.AdminServer.TLS != nil {
+		if err := c.AdminServer.TLS.Validate(); err != nil {
+			return fmt.Errorf("bad admin server tls config: %w", err)
+		}
+	}
+	if c.Metrics.ListenMultiaddr != "" {
+		metricsAddr, err := c.Metrics.ListenNetAddr()
+		if err != nil {
+			return fmt.Errorf("bad metrics listen address %s: %s", c.Metrics.ListenMultiaddr, err)
+		}
+		if metricsAddr == adminAddr {
+			return fmt.Errorf("metrics and admin server cannot listen on the same address: %s", metricsAddr)
+		}
+	}
+
+	switch c.Ingest.EntriesFormat {
+	case "", ChainEntriesFormat:
+		if c.Ingest.LinkedChunkSize < 1 {
+			return fmt.Errorf("linked chunk size must be at least 1; got: %d", c.Ingest.LinkedChunkSize)
+		}
+	case HamtEntriesFormat:
+		if err := c.Ingest.Hamt.Validate(); err != nil {
+			return fmt.Errorf("bad hamt entries config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown entries format: %s", c.Ingest.EntriesFormat)
+	}
+
+	switch c.Ingest.PublisherKind {
+	case "", DTSyncPublisherKind:
+	case HttpPublisherKind:
+		pubAddr, err := c.Ingest.HttpPublisher.ListenNetAddr()
+		if err != nil {
+			return fmt.Errorf("bad http publisher listen address %s: %s", c.Ingest.HttpPublisher.ListenMultiaddr, err)
+		}
+		if pubAddr == adminAddr {
+			return fmt.Errorf("http publisher and admin server cannot listen on the same address: %s", pubAddr)
+		}
+	default:
+		return fmt.Errorf("unknown publisher kind: %s", c.Ingest.PublisherKind)
+	}
+	return nil
+}
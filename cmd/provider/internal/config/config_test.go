@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -46,3 +47,126 @@ func TestPath(t *testing.T) {
 		t.Fatalf("wrong path %s:", path)
 	}
 }
+
+func TestValidate(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			Datastore:      NewDatastore(),
+			Ingest:         NewIngest(),
+			AdminServer:    NewAdminServer(),
+			ProviderServer: NewProviderServer(),
+			Metrics:        NewMetrics(),
+		}
+	}
+
+	if err := newConfig().Validate(); err != nil {
+		t.Fatalf("expected default config to be valid: %s", err)
+	}
+
+	cfg := newConfig()
+	cfg.Ingest.PublisherKind = HttpPublisherKind
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected default http publisher config to be valid: %s", err)
+	}
+
+	cfg.Ingest.HttpPublisher.ListenMultiaddr = "/ip4/0.0.0.0/tcp/3104"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected http publisher with non-http multiaddr to be invalid")
+	}
+
+	cfg.Ingest.HttpPublisher.ListenMultiaddr = cfg.AdminServer.ListenMultiaddr + "/http"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected http publisher and admin server on same address to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.Ingest.PublisherKind = "fish"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected unknown publisher kind to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.Datastore.Type = "fish"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected unknown datastore type to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.Ingest.LinkCacheDatastore = &Datastore{Type: BadgerDatastoreType}
+	cfg.Ingest.PopulateDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected separate link cache datastore to be valid: %s", err)
+	}
+	cfg.Ingest.LinkCacheDatastore.Dir = cfg.Datastore.Dir
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected link cache datastore in main datastore directory to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.Ingest.EntriesFormat = HamtEntriesFormat
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected default hamt entries config to be valid: %s", err)
+	}
+	cfg.Ingest.Hamt.HashFunc = "sha2-512"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected unsupported hamt hash function to be invalid")
+	}
+	cfg.Ingest.Hamt = NewHamtEntries()
+	cfg.Ingest.Hamt.BitWidth = 2
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected hamt bit-width less than 3 to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.ProviderServer.RetrievalMultiaddrs = []string{"/ip4/1.2.3.4/tcp/3103", "not-a-multiaddr"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected bad retrieval address to be invalid")
+	}
+
+	cfg = newConfig()
+	cfg.Metrics.ListenMultiaddr = cfg.AdminServer.ListenMultiaddr
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected metrics and admin server on same address to be invalid")
+	}
+	cfg.Metrics.ListenMultiaddr = ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected disabled metrics server to be valid: %s", err)
+	}
+
+	cfg = newConfig()
+	cfg.AdminServer.TLS = &AdminTLS{CertFile: "cert.pem"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected admin server TLS without key file to be invalid")
+	}
+	cfg.AdminServer.TLS.KeyFile = "key.pem"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected admin server TLS with cert and key files to be valid: %s", err)
+	}
+}
+
+func TestLoadKeepsDisabledMetrics(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultConfigFile)
+
+	if err := os.WriteFile(path, []byte(`{"Metrics":{"ListenMultiaddr":""}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Metrics.ListenMultiaddr != "" {
+		t.Fatalf("expected metrics server to remain disabled; got: %s", cfg.Metrics.ListenMultiaddr)
+	}
+
+	if err = os.WriteFile(path, []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Metrics.ListenMultiaddr != defaultMetricsAddr {
+		t.Fatalf("expected default metrics address when unspecified; got: %s", cfg.Metrics.ListenMultiaddr)
+	}
+}
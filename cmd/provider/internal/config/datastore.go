@@ -1,16 +1,76 @@
 package config
 
+import (
+	"fmt"
+	"time"
+)
+
 const (
-	defaultDatastoreType = "levelds"
+	defaultDatastoreType = LevelDSDatastoreType
 	defaultDatastoreDir  = "datastore"
+
+	defaultBadgerGcInterval     = Duration(15 * time.Minute)
+	defaultBadgerGcDiscardRatio = 0.2
+	defaultFlatFSShardFunc      = "/repo/flatfs/shard/v1/next-to-last/2"
+)
+
+const (
+	// LevelDSDatastoreType stores all data in a LevelDB datastore.
+	LevelDSDatastoreType = "levelds"
+	// BadgerDatastoreType stores all data in a Badger datastore.
+	BadgerDatastoreType = "badger"
+	// FlatFSDatastoreType stores cached entry chunks in a FlatFS datastore, and everything else in
+	// a LevelDB datastore.
+	FlatFSDatastoreType = "flatfs"
+	// MemoryDatastoreType stores all data in memory; all data is lost when the daemon stops.
+	MemoryDatastoreType = "memory"
 )
 
 // Datastore tracks the configuration of the datastore.
 type Datastore struct {
-	// Type is the type of datastore
+	// Type is the type of datastore. Supported types are "levelds", "badger", "flatfs" and
+	// "memory". Defaults to "levelds".
 	Type string
-	// Dir is the directory within the config root where the datastore is kept
+	// Dir is the directory within the config root where the datastore is kept. Ignored for
+	// "memory" datastore type.
 	Dir string
+
+	// LevelDS configures the LevelDB datastore, used by "levelds" and "flatfs" datastore types.
+	LevelDS LevelDSDatastore
+	// Badger configures the Badger datastore, used by "badger" datastore type.
+	Badger BadgerDatastore
+	// FlatFS configures the FlatFS datastore, used by "flatfs" datastore type.
+	FlatFS FlatFSDatastore
+}
+
+// LevelDSDatastore configures the LevelDB datastore.
+type LevelDSDatastore struct {
+	// NoSync disables syncing of writes to disk.
+	NoSync bool
+	// NoCompression disables the snappy compression of stored data.
+	NoCompression bool
+}
+
+// BadgerDatastore configures the Badger datastore.
+type BadgerDatastore struct {
+	// SyncWrites syncs every write to disk before returning.
+	SyncWrites bool
+	// GcInterval is the interval between value log garbage collection cycles. Defaults to 15
+	// minutes.
+	GcInterval Duration
+	// GcDiscardRatio is the ratio of discardable data in a value log file that triggers its
+	// garbage collection. Defaults to 0.2.
+	GcDiscardRatio float64
+}
+
+// FlatFSDatastore configures the FlatFS datastore.
+type FlatFSDatastore struct {
+	// ShardFunc is the sharding function used to lay out files across directories. It is only
+	// used when the datastore is created for the first time. Defaults to
+	// "/repo/flatfs/shard/v1/next-to-last/2".
+	ShardFunc string
+	// NoSync disables syncing of writes to disk.
+	NoSync bool
 }
 
 // NewDatastore instantiates a new Datastore config with default values.
@@ -18,6 +78,13 @@ func NewDatastore() Datastore {
 	return Datastore{
 		Type: defaultDatastoreType,
 		Dir:  defaultDatastoreDir,
+		Badger: BadgerDatastore{
+			GcInterval:     defaultBadgerGcInterval,
+			GcDiscardRatio: defaultBadgerGcDiscardRatio,
+		},
+		FlatFS: FlatFSDatastore{
+			ShardFunc: defaultFlatFSShardFunc,
+		},
 	}
 }
 
@@ -29,4 +96,23 @@ func (c *Datastore) PopulateDefaults() {
 	if c.Dir == "" {
 		c.Dir = defaultDatastoreDir
 	}
+	if c.Badger.GcInterval == 0 {
+		c.Badger.GcInterval = defaultBadgerGcInterval
+	}
+	if c.Badger.GcDiscardRatio == 0 {
+		c.Badger.GcDiscardRatio = defaultBadgerGcDiscardRatio
+	}
+	if c.FlatFS.ShardFunc == "" {
+		c.FlatFS.ShardFunc = defaultFlatFSShardFunc
+	}
+}
+
+// Validate checks that the datastore type is supported.
+func (c *Datastore) Validate() error {
+	switch c.Type {
+	case LevelDSDatastoreType, BadgerDatastoreType, FlatFSDatastoreType, MemoryDatastoreType:
+		return nil
+	default:
+		return fmt.Errorf("unsupported datastore type: %q", c.Type)
+	}
 }
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 )
 
 type HttpPublisher struct {
+	// ListenMultiaddr is the multiaddr string for the HTTP publisher listen address, which must
+	// end with the "http" protocol, e.g. "/ip4/0.0.0.0/tcp/3104/http".
 	ListenMultiaddr string
 }
 
@@ -16,13 +20,19 @@ func NewHttpPublisher() HttpPublisher {
 	}
 }
 
+// ListenNetAddr returns the net listen address of the HTTP publisher. An error is returned if the
+// listen multiaddr is not an HTTP multiaddr.
 func (hs *HttpPublisher) ListenNetAddr() (string, error) {
 	maddr, err := multiaddr.NewMultiaddr(hs.ListenMultiaddr)
 	if err != nil {
 		return "", err
 	}
 	httpMultiaddr, _ := multiaddr.NewMultiaddr("/http")
-	maddr = maddr.Decapsulate(httpMultiaddr)
+	netMaddr := maddr.Decapsulate(httpMultiaddr)
+	if netMaddr.Equal(maddr) || !netMaddr.Encapsulate(httpMultiaddr).Equal(maddr) {
+		return "", fmt.Errorf("not an http multiaddr: %s", hs.ListenMultiaddr)
+	}
+	maddr = netMaddr
 
 	netAddr, err := manet.ToNetAddr(maddr)
 	if err != nil {
@@ -12,6 +12,9 @@ import (
 type Identity struct {
 	PeerID  string
 	PrivKey string `json:",omitempty"`
+	// Previous lists the identities the node used before its identity was rotated, most recent
+	// first. Their keys are kept to sign advertisements about the content advertised under them.
+	Previous []Identity `json:",omitempty"`
 }
 
 func (i Identity) Decode() (peer.ID, ic.PrivKey, error) {
@@ -39,3 +42,25 @@ func (i Identity) DecodePrivateKey(passphrase string) (ic.PrivKey, error) {
 	// TODO(security)
 	return ic.UnmarshalPrivateKey(pkb)
 }
+
+// DecodePrevious decodes the private keys of the previous identities.
+func (i Identity) DecodePrevious() ([]ic.PrivKey, error) {
+	keys := make([]ic.PrivKey, 0, len(i.Previous))
+	for _, prev := range i.Previous {
+		privKey, err := prev.DecodePrivateKey("")
+		if err != nil {
+			return nil, fmt.Errorf("could not decode private key of previous identity %s: %s", prev.PeerID, err)
+		}
+		keys = append(keys, privKey)
+	}
+	return keys, nil
+}
+
+// Rotate returns a copy of the identity that uses the given new identity, and retains the current
+// one as the most recent previous identity.
+func (i Identity) Rotate(next Identity) Identity {
+	prev := i
+	prev.Previous = nil
+	next.Previous = append([]Identity{prev}, i.Previous...)
+	return next
+}
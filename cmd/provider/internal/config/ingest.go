@@ -1,18 +1,39 @@
 package config
 
+import (
+	"fmt"
+
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
 const (
 	// Keep 1024 chunks in cache; keeps 256MiB if chunks are 0.25MiB.
 	defaultLinkCacheSize = 1024
 	// Multihashes are 128 bytes so 16384 results in 0.25MiB chunk when full.
 	defaultLinkedChunkSize = 16384
 	defaultPubSubTopic     = "/indexer/ingest/mainnet"
+	// defaultLinkCacheDatastoreDir is the default directory of the link cache datastore, when
+	// configured.
+	defaultLinkCacheDatastoreDir = "linkcache"
+
+	// Defaults for HAMT entries, consistent with go-ipld-adl-hamt defaults.
+	defaultHamtHashFunc   = "murmur3-x64-64"
+	defaultHamtBitWidth   = 5
+	defaultHamtBucketSize = 3
 )
 
-type PublisherKind string
+type (
+	PublisherKind string
+	EntriesFormat string
+)
 
 const (
 	DTSyncPublisherKind PublisherKind = "dtsync"
 	HttpPublisherKind   PublisherKind = "http"
+
+	ChainEntriesFormat EntriesFormat = "chain"
+	HamtEntriesFormat  EntriesFormat = "hamt"
 )
 
 // Ingest configures settings related to the ingestion protocol.
@@ -26,10 +47,32 @@ type Ingest struct {
 	// setting LinkedChunkSize = 16384 will result in blocks of about 2Mb when
 	// full.
 	LinkedChunkSize int
+	// EntriesFormat is the format of the advertisement entries DAG; either "chain" for a chain of
+	// entry chunks, each with at most LinkedChunkSize multihashes, or "hamt" for a HAMT configured
+	// by Hamt. Defaults to "chain".
+	EntriesFormat EntriesFormat
+	// Hamt configures the HAMT advertisement entries, used when EntriesFormat is "hamt".
+	Hamt HamtEntries
 	// PubSubTopic used to advertise ingestion announcements.
 	PubSubTopic string
 	// PurgeLinkCache tells whether to purge the link cache on daemon startup.
 	PurgeLinkCache bool
+	// CheckMappings tells whether to check, on daemon startup, for advertisement mappings left
+	// half-applied by a crash, and report them in the logs. The check walks the entire
+	// advertisement chain, and so delays startup by time proportional to its length.
+	CheckMappings bool
+	// RepairMappings tells whether to repair, on daemon startup, the advertisement mappings left
+	// half-applied by a crash, rather than only reporting them in the logs. Repairing deletes the
+	// mappings of context IDs whose advertisement was never stored. Repairing implies
+	// CheckMappings.
+	RepairMappings bool
+	// LinkCacheDatastore optionally configures a separate datastore in which to store the link
+	// cache, e.g. on a different disk or using a different backend than the main datastore. If
+	// unset, the link cache is stored in the main datastore.
+	//
+	// Note that changing this setting does not remove the links cached in the previously used
+	// datastore.
+	LinkCacheDatastore *Datastore
 
 	// HttpPublisher configures the go-legs httpsync publisher.
 	HttpPublisher HttpPublisher
@@ -47,6 +90,8 @@ func NewIngest() Ingest {
 	return Ingest{
 		LinkCacheSize:   defaultLinkCacheSize,
 		LinkedChunkSize: defaultLinkedChunkSize,
+		EntriesFormat:   ChainEntriesFormat,
+		Hamt:            NewHamtEntries(),
 		PubSubTopic:     defaultPubSubTopic,
 		HttpPublisher:   NewHttpPublisher(),
 		PublisherKind:   DTSyncPublisherKind,
@@ -62,7 +107,80 @@ func (c *Ingest) PopulateDefaults() {
 	if c.LinkedChunkSize == 0 {
 		c.LinkedChunkSize = defaultLinkedChunkSize
 	}
+	if c.EntriesFormat == "" {
+		c.EntriesFormat = ChainEntriesFormat
+	}
+	c.Hamt.PopulateDefaults()
 	if c.PubSubTopic == "" {
 		c.PubSubTopic = defaultPubSubTopic
 	}
+	if c.LinkCacheDatastore != nil {
+		if c.LinkCacheDatastore.Dir == "" {
+			c.LinkCacheDatastore.Dir = defaultLinkCacheDatastoreDir
+		}
+		c.LinkCacheDatastore.PopulateDefaults()
+	}
+}
+
+// HamtEntries configures the HAMT advertisement entries.
+type HamtEntries struct {
+	// HashFunc is the name of the multihash function used to hash the HAMT keys; one of
+	// "identity", "sha2-256" or "murmur3-x64-64". Defaults to "murmur3-x64-64".
+	HashFunc string
+	// BitWidth is the number of bits of the hash used at each level of the HAMT; must be at
+	// least 3. Defaults to 5.
+	BitWidth int
+	// BucketSize is the maximum number of entries in each HAMT bucket; must be at least 1.
+	// Defaults to 3.
+	BucketSize int
+}
+
+// NewHamtEntries instantiates a new HamtEntries config with default values.
+func NewHamtEntries() HamtEntries {
+	return HamtEntries{
+		HashFunc:   defaultHamtHashFunc,
+		BitWidth:   defaultHamtBitWidth,
+		BucketSize: defaultHamtBucketSize,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *HamtEntries) PopulateDefaults() {
+	if c.HashFunc == "" {
+		c.HashFunc = defaultHamtHashFunc
+	}
+	if c.BitWidth == 0 {
+		c.BitWidth = defaultHamtBitWidth
+	}
+	if c.BucketSize == 0 {
+		c.BucketSize = defaultHamtBucketSize
+	}
+}
+
+// HashAlg returns the multicodec code of the configured hash function.
+func (c *HamtEntries) HashAlg() (multicodec.Code, error) {
+	mhc, ok := multihash.Names[c.HashFunc]
+	if !ok {
+		return 0, fmt.Errorf("no multihash code found with name: %s", c.HashFunc)
+	}
+	switch code := multicodec.Code(mhc); code {
+	case multicodec.Identity, multicodec.Sha2_256, multicodec.Murmur3X64_64:
+		return code, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash function: %s", c.HashFunc)
+	}
+}
+
+// Validate checks that the HAMT parameters are supported.
+func (c *HamtEntries) Validate() error {
+	if _, err := c.HashAlg(); err != nil {
+		return err
+	}
+	if c.BitWidth < 3 {
+		return fmt.Errorf("bit-width must be at least 3; got: %d", c.BitWidth)
+	}
+	if c.BucketSize < 1 {
+		return fmt.Errorf("bucket size must be at least 1; got: %d", c.BucketSize)
+	}
+	return nil
 }
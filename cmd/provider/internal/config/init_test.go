@@ -88,3 +88,62 @@ func TestSaveLoad(t *testing.T) {
 		t.Fatal("config data different after being loaded")
 	}
 }
+
+func TestRotateIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgFile, err := Filename(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Init(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := cfg.Identity
+
+	for i := 0; i < 2; i++ {
+		next, err := CreateIdentity(ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Identity = cfg.Identity.Rotate(next)
+		if err = cfg.Save(cfgFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No temporary files must be left behind by saving.
+	files, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatal("expected only the config file in the config dir, got", len(files))
+	}
+
+	cfg2, err := Load(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg2.Identity.PeerID != cfg.Identity.PeerID {
+		t.Fatal("identity not same")
+	}
+	if len(cfg2.Identity.Previous) != 2 {
+		t.Fatal("expected 2 previous identities, got", len(cfg2.Identity.Previous))
+	}
+	if cfg2.Identity.Previous[1].PeerID != first.PeerID {
+		t.Fatal("expected first identity to be the oldest previous identity")
+	}
+	for _, prev := range cfg2.Identity.Previous {
+		if len(prev.Previous) != 0 {
+			t.Fatal("expected previous identities not to be nested")
+		}
+	}
+	keys, err := cfg2.Identity.DecodePrevious()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatal("expected 2 previous keys, got", len(keys))
+	}
+}
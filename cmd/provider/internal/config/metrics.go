@@ -0,0 +1,36 @@
+package config
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const defaultMetricsAddr = "/ip4/127.0.0.1/tcp/3105"
+
+// Metrics configures the server that exposes Prometheus metrics at path /metrics.
+type Metrics struct {
+	// ListenMultiaddr is the metrics server listen address. The metrics server is disabled if
+	// empty.
+	ListenMultiaddr string
+}
+
+// NewMetrics instantiates a new Metrics config with default values.
+func NewMetrics() Metrics {
+	return Metrics{
+		ListenMultiaddr: defaultMetricsAddr,
+	}
+}
+
+// ListenNetAddr returns ListenMultiaddr as a net address.
+func (m *Metrics) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(m.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
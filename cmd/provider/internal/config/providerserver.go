@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
 type ProviderServer struct {
 	// ListenMultiaddr is the multiaddr string for the node's listen address
 	ListenMultiaddr string
@@ -23,3 +29,19 @@ func (c *ProviderServer) PopulateDefaults() {
 		c.ListenMultiaddr = def.ListenMultiaddr
 	}
 }
+
+// RetrievalAddrs returns the parsed RetrievalMultiaddrs, or nil if none are configured.
+func (c *ProviderServer) RetrievalAddrs() ([]multiaddr.Multiaddr, error) {
+	if len(c.RetrievalMultiaddrs) == 0 {
+		return nil, nil
+	}
+	maddrs := make([]multiaddr.Multiaddr, 0, len(c.RetrievalMultiaddrs))
+	for _, s := range c.RetrievalMultiaddrs {
+		maddr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad retrieval address %s: %s", s, err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	return maddrs, nil
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/index-provider/cmd/provider/internal/config"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	badgerds "github.com/ipfs/go-ds-badger"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	flatFSBlocksDir = "blocks"
+	flatFSMetaDir   = "meta"
+)
+
+// NewDatastore instantiates the datastore of the given type at the given directory. The directory
+// is ignored if the datastore type is config.MemoryDatastoreType.
+func NewDatastore(cfg config.Datastore, dir string) (datastore.Batching, error) {
+	switch cfg.Type {
+	case config.LevelDSDatastoreType:
+		return newLevelDS(cfg.LevelDS, dir)
+	case config.BadgerDatastoreType:
+		opts := badgerds.DefaultOptions
+		opts.SyncWrites = cfg.Badger.SyncWrites
+		opts.GcInterval = time.Duration(cfg.Badger.GcInterval)
+		opts.GcDiscardRatio = cfg.Badger.GcDiscardRatio
+		return badgerds.NewDatastore(dir, &opts)
+	case config.FlatFSDatastoreType:
+		shardFunc, err := flatfs.ParseShardFunc(cfg.FlatFS.ShardFunc)
+		if err != nil {
+			return nil, fmt.Errorf("bad flatfs shard function %s: %w", cfg.FlatFS.ShardFunc, err)
+		}
+		blocks, err := flatfs.CreateOrOpen(filepath.Join(dir, flatFSBlocksDir), shardFunc, !cfg.FlatFS.NoSync)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := newLevelDS(cfg.LevelDS, filepath.Join(dir, flatFSMetaDir))
+		if err != nil {
+			_ = blocks.Close()
+			return nil, err
+		}
+		return newSplitDatastore(meta, blocks), nil
+	case config.MemoryDatastoreType:
+		return dssync.MutexWrap(datastore.NewMapDatastore()), nil
+	default:
+		return nil, fmt.Errorf("unsupported datastore type: %q", cfg.Type)
+	}
+}
+
+func newLevelDS(cfg config.LevelDSDatastore, dir string) (*leveldb.Datastore, error) {
+	opts := &leveldb.Options{
+		NoSync: cfg.NoSync,
+	}
+	if cfg.NoCompression {
+		opts.Compression = opt.NoCompression
+	}
+	return leveldb.NewDatastore(dir, opts)
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// entriesCacheKey is the key under which the provider engine caches the entry chunks.
+var entriesCacheKey = datastore.NewKey("/cache/links")
+
+var (
+	_ datastore.Batching = (*splitDatastore)(nil)
+	_ datastore.Batch    = (*splitBatch)(nil)
+)
+
+// splitDatastore stores the cached entry chunk blocks in a blocks datastore, and everything else
+// in a meta datastore. This allows the bulk of provider data to be stored in a datastore optimised
+// for storing blocks, such as FlatFS, which only supports flat keys made up of upper case
+// alphanumeric characters.
+//
+// Cached entry chunk blocks are keyed by the lower case base32 string representation of their
+// CID, which makes them upper cased to form the blocks datastore key.
+type splitDatastore struct {
+	meta   datastore.Batching
+	blocks datastore.Batching
+}
+
+func newSplitDatastore(meta, blocks datastore.Batching) *splitDatastore {
+	return &splitDatastore{
+		meta:   meta,
+		blocks: blocks,
+	}
+}
+
+// blockKey returns the blocks datastore key corresponding to the given key, and whether the key
+// belongs to the blocks datastore.
+func blockKey(k datastore.Key) (datastore.Key, bool) {
+	if !k.Parent().Equal(entriesCacheKey) {
+		return datastore.Key{}, false
+	}
+	name := k.Name()
+	if name == "" {
+		return datastore.Key{}, false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return datastore.Key{}, false
+		}
+	}
+	return datastore.NewKey(strings.ToUpper(name)), true
+}
+
+// keyFromBlockKey is the inverse of blockKey.
+func keyFromBlockKey(bk datastore.Key) datastore.Key {
+	return entriesCacheKey.ChildString(strings.ToLower(bk.Name()))
+}
+
+func (s *splitDatastore) route(k datastore.Key) (datastore.Batching, datastore.Key) {
+	if bk, ok := blockKey(k); ok {
+		return s.blocks, bk
+	}
+	return s.meta, k
+}
+
+func (s *splitDatastore) Get(ctx context.Context, key datastore.Key) ([]byte, error) {
+	ds, k := s.route(key)
+	return ds.Get(ctx, k)
+}
+
+func (s *splitDatastore) Has(ctx context.Context, key datastore.Key) (bool, error) {
+	ds, k := s.route(key)
+	return ds.Has(ctx, k)
+}
+
+func (s *splitDatastore) GetSize(ctx context.Context, key datastore.Key) (int, error) {
+	ds, k := s.route(key)
+	return ds.GetSize(ctx, k)
+}
+
+func (s *splitDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	ds, k := s.route(key)
+	return ds.Put(ctx, k, value)
+}
+
+func (s *splitDatastore) Delete(ctx context.Context, key datastore.Key) error {
+	ds, k := s.route(key)
+	return ds.Delete(ctx, k)
+}
+
+// Query queries the meta datastore, and the blocks datastore if the query prefix may match the
+// entry chunk blocks. Filters, orders, offset and limit are applied to the combined results.
+func (s *splitDatastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	prefix := datastore.NewKey(q.Prefix)
+	childQuery := query.Query{Prefix: prefix.String(), KeysOnly: q.KeysOnly, ReturnsSizes: q.ReturnsSizes}
+
+	metaResults, err := s.meta.Query(ctx, childQuery)
+	if err != nil {
+		return nil, err
+	}
+	if !prefix.Equal(entriesCacheKey) && !prefix.IsAncestorOf(entriesCacheKey) {
+		return query.NaiveQueryApply(q, metaResults), nil
+	}
+
+	// FlatFS only supports querying all keys.
+	childQuery.Prefix = "/"
+	blockResults, err := s.blocks.Query(ctx, childQuery)
+	if err != nil {
+		_ = metaResults.Close()
+		return nil, err
+	}
+
+	results := query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			if r, ok := metaResults.NextSync(); ok {
+				return r, true
+			}
+			r, ok := blockResults.NextSync()
+			if ok && r.Error == nil {
+				r.Key = keyFromBlockKey(datastore.RawKey(r.Key)).String()
+			}
+			return r, ok
+		},
+		Close: func() error {
+			var errs error
+			if err := metaResults.Close(); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			if err := blockResults.Close(); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			return errs
+		},
+	})
+	return query.NaiveQueryApply(q, results), nil
+}
+
+func (s *splitDatastore) Sync(ctx context.Context, prefix datastore.Key) error {
+	if err := s.meta.Sync(ctx, prefix); err != nil {
+		return err
+	}
+	if prefix.Equal(entriesCacheKey) || prefix.IsAncestorOf(entriesCacheKey) {
+		return s.blocks.Sync(ctx, datastore.NewKey("/"))
+	}
+	return nil
+}
+
+func (s *splitDatastore) Close() error {
+	var errs error
+	if err := s.meta.Close(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if err := s.blocks.Close(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	return errs
+}
+
+func (s *splitDatastore) Batch(ctx context.Context) (datastore.Batch, error) {
+	meta, err := s.meta.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.blocks.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &splitBatch{
+		meta:   meta,
+		blocks: blocks,
+	}, nil
+}
+
+type splitBatch struct {
+	meta   datastore.Batch
+	blocks datastore.Batch
+}
+
+func (b *splitBatch) route(k datastore.Key) (datastore.Batch, datastore.Key) {
+	if bk, ok := blockKey(k); ok {
+		return b.blocks, bk
+	}
+	return b.meta, k
+}
+
+func (b *splitBatch) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	batch, k := b.route(key)
+	return batch.Put(ctx, k, value)
+}
+
+func (b *splitBatch) Delete(ctx context.Context, key datastore.Key) error {
+	batch, k := b.route(key)
+	return batch.Delete(ctx, k)
+}
+
+func (b *splitBatch) Commit(ctx context.Context) error {
+	if err := b.blocks.Commit(ctx); err != nil {
+		return err
+	}
+	return b.meta.Commit(ctx)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDatastore(t *testing.T) {
+	ctx := context.Background()
+
+	blocks, err := flatfs.CreateOrOpen(t.TempDir(), flatfs.NextToLast(2), false)
+	require.NoError(t, err)
+	meta := datastore.NewMapDatastore()
+	subject := newSplitDatastore(meta, blocks)
+	defer subject.Close()
+
+	blockKey := datastore.NewKey("/cache/links/bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+	rootKey := datastore.NewKey("/cache/links/root/bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+	mapKey := datastore.NewKey("/map/keyCid/fish")
+
+	batch, err := subject.Batch(ctx)
+	require.NoError(t, err)
+	require.NoError(t, batch.Put(ctx, blockKey, []byte("block")))
+	require.NoError(t, batch.Put(ctx, rootKey, []byte("root")))
+	require.NoError(t, batch.Commit(ctx))
+	require.NoError(t, subject.Put(ctx, mapKey, []byte("map")))
+
+	// Assert entry chunk blocks are stored in the blocks datastore only.
+	has, err := blocks.Has(ctx, datastore.NewKey("BAFKREIGH2AKISCAILDCQABSYG3DFR6CHU3FGPREGIYMSCK7E7AQA4S52ZY"))
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = meta.Has(ctx, blockKey)
+	require.NoError(t, err)
+	require.False(t, has)
+	has, err = meta.Has(ctx, rootKey)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	got, err := subject.Get(ctx, blockKey)
+	require.NoError(t, err)
+	require.Equal(t, []byte("block"), got)
+	size, err := subject.GetSize(ctx, blockKey)
+	require.NoError(t, err)
+	require.Equal(t, len("block"), size)
+
+	queryKeys := func(prefix string) []string {
+		results, err := subject.Query(ctx, query.Query{Prefix: prefix, KeysOnly: true})
+		require.NoError(t, err)
+		entries, err := results.Rest()
+		require.NoError(t, err)
+		var keys []string
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	require.Equal(t, []string{blockKey.String(), rootKey.String(), mapKey.String()}, queryKeys("/"))
+	require.Equal(t, []string{blockKey.String(), rootKey.String()}, queryKeys("/cache/links"))
+	require.Equal(t, []string{rootKey.String()}, queryKeys("/cache/links/root"))
+	require.Equal(t, []string{mapKey.String()}, queryKeys("/map"))
+
+	require.NoError(t, subject.Delete(ctx, blockKey))
+	_, err = subject.Get(ctx, blockKey)
+	require.Equal(t, datastore.ErrNotFound, err)
+}
@@ -68,9 +68,7 @@ var (
 		Name:   "car",
 		Usage:  "Lists the local paths to CAR files provided by an standalone instance of index-provider daemon.",
 		Action: doListCars,
-		Flags: []cli.Flag{
-			adminAPIFlag,
-		},
+		Flags:  adminAPIFlags,
 	}
 )
 
@@ -167,8 +165,11 @@ func doGetAdvertisements(cctx *cli.Context) error {
 }
 
 func doListCars(cctx *cli.Context) error {
-	cl := &http.Client{}
-	resp, err := cl.Get(adminAPIFlagValue + "/admin/list/car")
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/list/car", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := doAdminReq(req)
 	if err != nil {
 		return err
 	}
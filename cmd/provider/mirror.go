@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"time"
 
+	"github.com/filecoin-project/index-provider/metrics"
 	"github.com/filecoin-project/index-provider/mirror"
 	leveldb "github.com/ipfs/go-ds-leveldb"
 	logging "github.com/ipfs/go-log/v2"
@@ -35,6 +38,7 @@ var Mirror struct {
 		topic                       *cli.StringFlag
 		skipRemapOnEntriesTypeMatch *cli.BoolFlag
 		alwaysReSignAds             *cli.BoolFlag
+		metricsListenAddr           *cli.StringFlag
 	}
 
 	source  *peer.AddrInfo
@@ -113,6 +117,11 @@ func init() {
 		Usage:       "Whether to always re-sign advertisements with the mirror's identity.",
 		DefaultText: "Ads are only re-singed if changed by the mirror.",
 	}
+	Mirror.flags.metricsListenAddr = &cli.StringFlag{
+		Name:        "metricsListenAddr",
+		Usage:       "The net address on which to serve Prometheus metrics at path /metrics, e.g. `127.0.0.1:3105`.",
+		DefaultText: "Metrics are not served",
+	}
 	Mirror.Command = &cli.Command{
 		Name:  "mirror",
 		Usage: "Mirrors the advertisement chain from an existing index provider.",
@@ -131,6 +140,7 @@ func init() {
 			Mirror.flags.topic,
 			Mirror.flags.skipRemapOnEntriesTypeMatch,
 			Mirror.flags.alwaysReSignAds,
+			Mirror.flags.metricsListenAddr,
 		},
 		Before: beforeMirror,
 		Action: doMirror,
@@ -235,6 +245,18 @@ func doMirror(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if cctx.IsSet(Mirror.flags.metricsListenAddr.Name) {
+		metricsSvr, err := metrics.NewServer(Mirror.flags.metricsListenAddr.Get(cctx))
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := metricsSvr.Start(); err != nil && err != http.ErrServerClosed {
+				log.Errorw("Failed to serve metrics", "err", err)
+			}
+		}()
+		defer metricsSvr.Shutdown(context.Background())
+	}
 	if err = m.Start(); err != nil {
 		return err
 	}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var PolicyCmd = &cli.Command{
+	Name:  "policy",
+	Usage: "Manages the policy that determines which indexers are allowed to sync from the provider.",
+	Description: `Allows or blocks indexer peers from syncing advertisements and entries from the provider
+daemon, or lists the current sync policy. Changes take effect immediately, and are written to the
+daemon's config file.
+
+Syncs over HTTP are anonymous; they are only allowed if peers are allowed by default.`,
+	Subcommands: []*cli.Command{allowPolicySubCmd, blockPolicySubCmd, listPolicySubCmd},
+}
+
+var allowPolicySubCmd = &cli.Command{
+	Name:   "allow",
+	Usage:  "Allows a peer to sync from the provider.",
+	Flags:  policyPeerFlags,
+	Action: doAllowPolicy,
+}
+
+var blockPolicySubCmd = &cli.Command{
+	Name:   "block",
+	Usage:  "Blocks a peer from syncing from the provider.",
+	Flags:  policyPeerFlags,
+	Action: doBlockPolicy,
+}
+
+var listPolicySubCmd = &cli.Command{
+	Name:   "list",
+	Usage:  "Lists the sync policy.",
+	Flags:  listPolicyFlags,
+	Action: doListPolicy,
+}
+
+func doAllowPolicy(cctx *cli.Context) error {
+	return postPolicyReq(cctx, true)
+}
+
+func doBlockPolicy(cctx *cli.Context) error {
+	return postPolicyReq(cctx, false)
+}
+
+func postPolicyReq(cctx *cli.Context, allow bool) error {
+	req := adminserver.PolicyReq{
+		Peer:  policyPeerFlagValue,
+		Allow: allow,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/policy", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	return printPolicy(cctx, resp)
+}
+
+func doListPolicy(cctx *cli.Context) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/policy", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	return printPolicy(cctx, resp)
+}
+
+func printPolicy(cctx *cli.Context, resp *http.Response) error {
+	var res adminserver.PolicyRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+
+	var b bytes.Buffer
+	if res.Allow {
+		b.WriteString("Peers are allowed by default.\n")
+		b.WriteString("Blocked peers:")
+	} else {
+		b.WriteString("Peers are blocked by default.\n")
+		b.WriteString("Allowed peers:")
+	}
+	if len(res.Except) == 0 {
+		b.WriteString(" none")
+	}
+	b.WriteString("\n")
+	for _, p := range res.Except {
+		fmt.Fprintf(&b, "\t%s\n", p)
+	}
+	_, err := cctx.App.Writer.Write(b.Bytes())
+	return err
+}
@@ -43,15 +43,26 @@ func run() int {
 		Commands: []*cli.Command{
 			AnnounceCmd,
 			AnnounceHttpCmd,
+			AnnounceURLCmd,
+			ChainCmd,
 			ConnectCmd,
+			ContextIDCmd,
 			DaemonCmd,
+			DatastoreCmd,
+			ExportChainCmd,
 			FindCmd,
+			GCCmd,
+			IdentityCmd,
 			ImportCmd,
+			ImportChainCmd,
 			IndexCmd,
 			InitCmd,
 			ListCmd,
+			PolicyCmd,
 			RegisterCmd,
 			RemoveCmd,
+			StatusCmd,
+			VerifyChainCmd,
 			VerifyIngestCmd,
 			Mirror.Command,
 		},
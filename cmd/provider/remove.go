@@ -16,7 +16,7 @@ var RemoveCmd = &cli.Command{
 	Name:        "remove",
 	Aliases:     []string{"rm"},
 	Usage:       "Removes previously advertised multihashes by the provider.",
-	Subcommands: []*cli.Command{removeCarSubCmd},
+	Subcommands: []*cli.Command{removeCarSubCmd, removeProviderSubCmd},
 }
 
 var (
@@ -43,6 +43,19 @@ specified, they key is simply calculated as the SHA_256 hash of the given path.`
 	}
 )
 
+var removeProviderSubCmd = &cli.Command{
+	Name:    "provider",
+	Aliases: []string{"p"},
+	Usage:   "Removes all multihashes previously advertised for a provider.",
+	Description: `Publishes one removal advertisement per context ID that is currently advertised for
+the given provider, and removes all local state associated to them. Only the resulting head of the
+advertisement chain is announced.
+
+This is useful for retiring a provider identity entirely, for example a decommissioned miner.`,
+	Flags:  removeProviderFlags,
+	Action: doRemoveProvider,
+}
+
 func beforeRemoveCar(cctx *cli.Context) error {
 	if !cctx.IsSet(keyFlag.Name) {
 		if !cctx.IsSet(optionalCarPathFlag.Name) {
@@ -92,3 +105,34 @@ func doRemoveCar(cctx *cli.Context) error {
 	_, err = cctx.App.Writer.Write(b.Bytes())
 	return err
 }
+
+func doRemoveProvider(cctx *cli.Context) error {
+	req := adminserver.RemoveProviderReq{
+		Provider: providerIDFlagValue,
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/remove/provider", req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.RemoveProviderRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	var b bytes.Buffer
+	b.WriteString("Successfully removed provider.\n")
+	b.WriteString("\t Provider ID: ")
+	b.WriteString(providerIDFlagValue)
+	b.WriteString("\n\t Removal Advertisement IDs:\n")
+	for _, advID := range res.AdvIds {
+		b.WriteString("\t   ")
+		b.WriteString(advID.String())
+		b.WriteString("\n")
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
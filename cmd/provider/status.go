@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+var StatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Shows the advertisements and entries synced by indexers from the provider.",
+	Description: `Shows the latest advertisement synced by each indexer since the provider daemon was started,
+along with the number of advertisements published since, i.e. the lag, and the number of entries
+blocks served to it.
+
+Syncs over HTTP are anonymous, and are shown as a single indexer.`,
+	Flags:  statusFlags,
+	Action: doStatus,
+}
+
+func doStatus(cctx *cli.Context) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/sync-status", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.SyncStatusRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("Latest advertisement: ")
+	if res.LatestAdvId == cid.Undef {
+		b.WriteString("none")
+	} else {
+		b.WriteString(res.LatestAdvId.String())
+	}
+	b.WriteString("\n")
+	if len(res.Indexers) == 0 {
+		b.WriteString("No indexers have synced yet.\n")
+	}
+	for _, indexer := range res.Indexers {
+		peer := indexer.Peer
+		if peer == "" {
+			peer = "anonymous (http)"
+		}
+		fmt.Fprintf(&b, "Indexer: %s\n", peer)
+		switch {
+		case indexer.LastAdvId == cid.Undef:
+			b.WriteString("\t Last advertisement: none\n")
+		case indexer.Lag < 0:
+			fmt.Fprintf(&b, "\t Last advertisement: %s (no longer in chain)\n", indexer.LastAdvId)
+		default:
+			fmt.Fprintf(&b, "\t Last advertisement: %s (lag: %d)\n", indexer.LastAdvId, indexer.Lag)
+		}
+		if indexer.LastAdvId != cid.Undef {
+			fmt.Fprintf(&b, "\t Synced at: %s\n", indexer.LastAdvSyncedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(&b, "\t Entries blocks served: %d\n", indexer.EntriesBlocksServed)
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
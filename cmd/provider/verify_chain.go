@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	adminserver "github.com/filecoin-project/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+var VerifyChainCmd = &cli.Command{
+	Name:  "verify-chain",
+	Usage: "Verifies the integrity of the advertisement chain stored by the provider.",
+	Description: `Walks the advertisement chain from the latest advertisement and checks that every
+advertisement exists, is valid and carries a valid signature, and that the entries of every currently
+advertised context ID resolve. It also checks that every context ID mapped to entries is referenced
+by an advertisement in the chain.
+
+The problems found are printed, in which case the command exits with a non-zero status. Useful for
+checking the datastore of the provider after an unclean shutdown or a disk failure.`,
+	Flags:  verifyChainFlags,
+	Action: doVerifyChain,
+}
+
+func doVerifyChain(cctx *cli.Context) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, adminAPIFlagValue+"/admin/chain/verify", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAdminReq(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Handle failed requests
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.VerifyChainRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+
+	var b bytes.Buffer
+	if res.Head == cid.Undef {
+		b.WriteString("No advertisements published.\n")
+	} else {
+		fmt.Fprintf(&b, "Verified %d advertisements and %d context ID mappings from head: %s\n", res.Advertisements, res.Mappings, res.Head)
+	}
+	for _, problem := range res.Problems {
+		if problem.AdvId != cid.Undef {
+			fmt.Fprintf(&b, "  advertisement %s", problem.AdvId)
+		} else {
+			fmt.Fprintf(&b, "  context ID %s of provider %s", base64.StdEncoding.EncodeToString(problem.ContextID), problem.Provider)
+		}
+		fmt.Fprintf(&b, ": %s\n", problem.Problem)
+	}
+	if _, err = cctx.App.Writer.Write(b.Bytes()); err != nil {
+		return err
+	}
+	if len(res.Problems) != 0 {
+		return cli.Exit(fmt.Sprintf("Advertisement chain is corrupt; found %d problems.", len(res.Problems)), 1)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+const announceRetryPrefix = "sync/announceRetry/"
+
+// AnnounceRetry captures a direct HTTP announcement to an indexer that failed and is queued for
+// retry. At most one announcement is queued per indexer URL; announcing a newer advertisement
+// supersedes the queued one, since indexers sync the chain from the announced advertisement.
+//
+// See: WithAnnounceRetry, Engine.AnnounceRetries.
+type AnnounceRetry struct {
+	// URL is the URL of the indexer to announce to.
+	URL string `json:"url"`
+	// AdCid is the CID of the advertisement to announce.
+	AdCid cid.Cid `json:"ad"`
+	// Attempts is the number of failed attempts so far.
+	Attempts int `json:"attempts"`
+	// FirstFailedAt is the time at which announcing to the indexer first failed. The announcement
+	// is dropped once the maximum age has elapsed since this time.
+	FirstFailedAt time.Time `json:"firstFailedAt"`
+	// NextAttemptAt is the time after which the announcement is next retried.
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// LastErr is the error that occurred during the last attempt.
+	LastErr string `json:"lastErr"`
+}
+
+// AnnounceRetries returns the direct HTTP announcements that are queued for retry, sorted by the
+// time at which they are next retried.
+func (e *Engine) AnnounceRetries(ctx context.Context) ([]AnnounceRetry, error) {
+	e.retryLk.Lock()
+	defer e.retryLk.Unlock()
+	var retries []AnnounceRetry
+	err := e.forEachAnnounceRetry(ctx, func(r AnnounceRetry) error {
+		retries = append(retries, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(retries, func(i, j int) bool { return retries[i].NextAttemptAt.Before(retries[j].NextAttemptAt) })
+	return retries, nil
+}
+
+// recordHttpAnnounces queues the failed direct HTTP announcements of the given advertisement for
+// retry, and discards any queued announcements to the indexers that were announced to
+// successfully, where errs holds the outcome of announcing to each of the given URLs.
+func (e *Engine) recordHttpAnnounces(ctx context.Context, adCid cid.Cid, announceURLs []*url.URL, errs []error) {
+	e.retryLk.Lock()
+	defer e.retryLk.Unlock()
+	for i, u := range announceURLs {
+		key := announceRetryKey(u.String())
+		if errs[i] == nil {
+			if err := e.ds.Delete(ctx, key); err != nil {
+				log.Errorw("Failed to discard queued announce retry", "url", u, "err", err)
+			}
+			continue
+		}
+		if e.announceRetryMaxAge == 0 {
+			continue
+		}
+		r, err := e.getAnnounceRetry(ctx, key)
+		if err == datastore.ErrNotFound {
+			r = AnnounceRetry{URL: u.String(), FirstFailedAt: time.Now()}
+		} else if err != nil {
+			log.Errorw("Failed to get queued announce retry", "url", u, "err", err)
+			continue
+		}
+		r.AdCid = adCid
+		e.failAnnounceRetry(&r, errs[i])
+		if err := e.putAnnounceRetry(ctx, r); err != nil {
+			log.Errorw("Failed to queue announce retry", "url", u, "err", err)
+			continue
+		}
+		log.Warnw("Queued failed http announce for retry", "url", u, "adCid", adCid, "nextAttemptAt", r.NextAttemptAt)
+	}
+}
+
+// failAnnounceRetry records a failed attempt of the given retry, and schedules its next attempt
+// with exponential backoff.
+func (e *Engine) failAnnounceRetry(r *AnnounceRetry, err error) {
+	backoff := e.announceRetryInitialBackoff
+	for i := 0; i < r.Attempts && backoff < e.announceRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > e.announceRetryMaxBackoff {
+		backoff = e.announceRetryMaxBackoff
+	}
+	r.Attempts++
+	r.NextAttemptAt = time.Now().Add(backoff)
+	r.LastErr = err.Error()
+}
+
+// retryAnnounces periodically retries the queued direct HTTP announcements that are due, until
+// the given context is cancelled.
+func (e *Engine) retryAnnounces(ctx context.Context) {
+	defer close(e.retryDone)
+	ticker := time.NewTicker(e.announceRetryInitialBackoff)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.retryDueAnnounces(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Errorw("Failed to retry queued http announces", "err", err)
+			}
+		}
+	}
+}
+
+func (e *Engine) retryDueAnnounces(ctx context.Context) error {
+	var due []AnnounceRetry
+	now := time.Now()
+	current := make(map[string]struct{})
+	for _, u := range e.AnnounceURLs() {
+		current[u.String()] = struct{}{}
+	}
+	e.retryLk.Lock()
+	err := e.forEachAnnounceRetry(ctx, func(r AnnounceRetry) error {
+		if _, ok := current[r.URL]; !ok {
+			log.Infow("Dropped queued http announce to removed URL", "url", r.URL, "adCid", r.AdCid)
+			return e.ds.Delete(ctx, announceRetryKey(r.URL))
+		}
+		if now.Sub(r.FirstFailedAt) >= e.announceRetryMaxAge {
+			log.Warnw("Dropped queued http announce older than max age", "url", r.URL, "adCid", r.AdCid, "attempts", r.Attempts)
+			return e.ds.Delete(ctx, announceRetryKey(r.URL))
+		}
+		if !now.Before(r.NextAttemptAt) {
+			due = append(due, r)
+		}
+		return nil
+	})
+	e.retryLk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return err
+		}
+		errs, err := e.sendHttpAnnounces(ctx, r.AdCid, []*url.URL{u})
+		if err != nil {
+			return err
+		}
+		if err := e.updateAnnounceRetry(ctx, r, errs[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateAnnounceRetry records the outcome of retrying the given announcement, unless it was
+// superseded while being retried.
+func (e *Engine) updateAnnounceRetry(ctx context.Context, attempted AnnounceRetry, err error) error {
+	e.retryLk.Lock()
+	defer e.retryLk.Unlock()
+	key := announceRetryKey(attempted.URL)
+	r, getErr := e.getAnnounceRetry(ctx, key)
+	if getErr == datastore.ErrNotFound {
+		return nil
+	}
+	if getErr != nil {
+		return getErr
+	}
+	if r.AdCid != attempted.AdCid {
+		return nil
+	}
+	if err == nil {
+		log.Infow("Retried http announce successfully", "url", r.URL, "adCid", r.AdCid, "attempts", r.Attempts)
+		return e.ds.Delete(ctx, key)
+	}
+	e.failAnnounceRetry(&r, err)
+	log.Warnw("Failed to retry http announce", "url", r.URL, "adCid", r.AdCid, "attempts", r.Attempts, "nextAttemptAt", r.NextAttemptAt, "err", err)
+	return e.putAnnounceRetry(ctx, r)
+}
+
+func announceRetryKey(u string) datastore.Key {
+	return datastore.NewKey(announceRetryPrefix + base64.RawURLEncoding.EncodeToString([]byte(u)))
+}
+
+func (e *Engine) getAnnounceRetry(ctx context.Context, key datastore.Key) (AnnounceRetry, error) {
+	var r AnnounceRetry
+	data, err := e.ds.Get(ctx, key)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+func (e *Engine) putAnnounceRetry(ctx context.Context, r AnnounceRetry) error {
+	data, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+	return e.ds.Put(ctx, announceRetryKey(r.URL), data)
+}
+
+func (e *Engine) forEachAnnounceRetry(ctx context.Context, fn func(AnnounceRetry) error) error {
+	results, err := e.ds.Query(ctx, query.Query{Prefix: announceRetryPrefix})
+	if err != nil {
+		return err
+	}
+	// Collect the retries first, since fn may delete them and deleting while querying is not safe
+	// across datastores.
+	var retries []AnnounceRetry
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		var retry AnnounceRetry
+		if err := json.Unmarshal(r.Value, &retry); err != nil {
+			results.Close()
+			return err
+		}
+		retries = append(retries, retry)
+	}
+	results.Close()
+	for _, r := range retries {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// announcer coalesces the announcement of new advertisements such that only the latest head of
+// the advertisement chain is announced once either a time window has elapsed since the first
+// pending advertisement, or a number of new advertisements are pending; whichever happens first.
+//
+// See: WithAnnounceCoalescing.
+type announcer struct {
+	// window is the maximum duration to wait for before announcing pending advertisements. Zero
+	// means no time based announcement.
+	window time.Duration
+	// count is the number of pending advertisements that trigger an announcement. Zero means no
+	// count based announcement.
+	count int
+	// announce is the function called to announce the latest pending advertisement.
+	announce func(context.Context, cid.Cid) error
+
+	// lock synchronizes access to the pending state.
+	lock         sync.Mutex
+	pending      cid.Cid
+	pendingCount int
+	timer        *time.Timer
+
+	// flushLock serializes announcements so that an older head is never announced after a newer
+	// one.
+	flushLock sync.Mutex
+}
+
+func newAnnouncer(window time.Duration, count int, announce func(context.Context, cid.Cid) error) *announcer {
+	return &announcer{
+		window:   window,
+		count:    count,
+		announce: announce,
+	}
+}
+
+// add marks the given advertisement CID as the latest head to announce, accounting for n new
+// advertisements. An announcement is made immediately, using the given context, if the number of
+// pending advertisements reaches the configured count.
+func (a *announcer) add(ctx context.Context, c cid.Cid, n int) error {
+	a.lock.Lock()
+	a.pending = c
+	a.pendingCount += n
+	full := a.count > 0 && a.pendingCount >= a.count
+	if !full {
+		a.startTimer()
+	}
+	a.lock.Unlock()
+
+	if full {
+		return a.flush(ctx)
+	}
+	return nil
+}
+
+// startTimer schedules a flush once the window elapses, unless one is already scheduled or there is
+// no window. The lock must be held by the caller.
+func (a *announcer) startTimer() {
+	if a.timer != nil || a.window <= 0 {
+		return
+	}
+	a.timer = time.AfterFunc(a.window, func() {
+		if err := a.flush(context.Background()); err != nil {
+			log.Errorw("Failed to announce coalesced advertisements", "err", err)
+		}
+	})
+}
+
+// flush announces the latest pending advertisement, if any. If the announcement fails, the
+// advertisements remain pending so that they are announced by a later flush.
+func (a *announcer) flush(ctx context.Context) error {
+	a.flushLock.Lock()
+	defer a.flushLock.Unlock()
+
+	a.lock.Lock()
+	c := a.pending
+	count := a.pendingCount
+	a.pending = cid.Undef
+	a.pendingCount = 0
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	a.lock.Unlock()
+
+	if c == cid.Undef {
+		return nil
+	}
+	log.Infow("Announcing coalesced advertisements", "head", c, "count", count)
+	if err := a.announce(ctx, c); err != nil {
+		a.lock.Lock()
+		// Keep any head added since, as it is newer and its announcement covers c.
+		if a.pending == cid.Undef {
+			a.pending = c
+		}
+		a.pendingCount += count
+		a.startTimer()
+		a.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// close announces the latest pending advertisement, if any, and stops any scheduled flush, even if
+// the announcement fails.
+func (a *announcer) close(ctx context.Context) error {
+	err := a.flush(ctx)
+	a.lock.Lock()
+	a.window = 0
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	a.lock.Unlock()
+	return err
+}
+
+// pendingLen returns the number of advertisements that are not yet announced.
+func (a *announcer) pendingLen() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.pendingCount
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncer_FailedFlushKeepsPending(t *testing.T) {
+	ctx := context.Background()
+	cids := testutil.RandomCids(t, rand.New(rand.NewSource(1413)), 3)
+
+	failure := errors.New("fish")
+	var announced []cid.Cid
+	var fail bool
+	subject := newAnnouncer(0, 0, func(_ context.Context, c cid.Cid) error {
+		if fail {
+			return failure
+		}
+		announced = append(announced, c)
+		return nil
+	})
+
+	require.NoError(t, subject.add(ctx, cids[0], 1))
+	require.NoError(t, subject.add(ctx, cids[1], 2))
+
+	fail = true
+	require.Equal(t, failure, subject.flush(ctx))
+	require.Equal(t, 3, subject.pendingLen())
+
+	// The next flush announces the head that failed to be announced.
+	fail = false
+	require.NoError(t, subject.flush(ctx))
+	require.Equal(t, 0, subject.pendingLen())
+	require.Equal(t, []cid.Cid{cids[1]}, announced)
+
+	// A head added after a failed flush supersedes the one that failed.
+	require.NoError(t, subject.add(ctx, cids[0], 1))
+	fail = true
+	require.Equal(t, failure, subject.flush(ctx))
+	require.NoError(t, subject.add(ctx, cids[2], 1))
+	fail = false
+	require.NoError(t, subject.flush(ctx))
+	require.Equal(t, 0, subject.pendingLen())
+	require.Equal(t, []cid.Cid{cids[1], cids[2]}, announced)
+}
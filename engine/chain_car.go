@@ -0,0 +1,460 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var (
+	// ErrNoAdvertisements signals that there are no advertisements to export.
+	ErrNoAdvertisements = errors.New("no advertisements published")
+	// ErrChainExists signals that an advertisement chain cannot be imported since the engine
+	// already has one.
+	ErrChainExists = errors.New("advertisement chain already exists")
+	// ErrChainImporting signals that an advertisement cannot be published since an advertisement
+	// chain is being imported.
+	ErrChainImporting = errors.New("advertisement chain import in progress")
+	// ErrChainSignerMismatch signals that an advertisement chain cannot be imported since it was
+	// not signed by the key with which the engine signs advertisements.
+	ErrChainSignerMismatch = errors.New("advertisement chain not signed by engine signer")
+)
+
+// ExportChain writes the advertisement chain to a new CARv2 file at the given path, with the
+// latest advertisement as its root. If withEntries is true, the entries of the context IDs that
+// are currently advertised are written too; entries that are not cached are regenerated via the
+// registered multihash lister. The entries of superseded or removed context IDs are never written,
+// since they may no longer be available to the lister.
+//
+// The file must not already exist, and is removed if the export fails. ErrNoAdvertisements is
+// returned if there are no advertisements published.
+//
+// This function returns the CID of the latest advertisement and the number of advertisements
+// written.
+func (e *Engine) ExportChain(ctx context.Context, path string, withEntries bool) (cid.Cid, int, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, 0, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if head == cid.Undef {
+		return cid.Undef, 0, ErrNoAdvertisements
+	}
+	// The CARv2 blockstore resumes writing to existing files; refuse to touch them instead.
+	if _, err = os.Stat(path); err == nil {
+		return cid.Undef, 0, fmt.Errorf("file already exists: %s", path)
+	}
+	bs, err := blockstore.OpenReadWrite(path, []cid.Cid{head})
+	if err != nil {
+		return cid.Undef, 0, fmt.Errorf("cannot create CAR file: %w", err)
+	}
+	count, err := e.exportChain(ctx, bs, head, withEntries)
+	if err == nil {
+		err = bs.Finalize()
+	}
+	if err != nil {
+		bs.Discard()
+		if rmErr := os.Remove(path); rmErr != nil {
+			log.Warnw("Failed to remove incomplete CAR file", "path", path, "err", rmErr)
+		}
+		return cid.Undef, 0, err
+	}
+	log.Infow("Exported advertisement chain", "head", head, "count", count, "path", path, "withEntries", withEntries)
+	return head, count, nil
+}
+
+func (e *Engine) exportChain(ctx context.Context, bs *blockstore.ReadWrite, head cid.Cid, withEntries bool) (int, error) {
+	// Mapped entries are keyed by entries CID and context ID, as in Engine.VerifyChain.
+	mapped := make(map[string]struct{})
+	if withEntries {
+		err := e.forEachKeyCidMapping(ctx, func(_ peer.ID, contextID []byte, c cid.Cid) error {
+			mapped[string(c.Bytes())+string(contextID)] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("could not list context ID mappings: %w", err)
+		}
+	}
+
+	adLsys := teeLinkSystem(ctx, e.vanillaLinkSystem(), bs)
+	entriesLsys := teeLinkSystem(ctx, e.lsys, bs)
+	exported := make(map[cid.Cid]struct{})
+	var count int
+	for c := head; c != cid.Undef; {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		n, err := adLsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return count, fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return count, err
+		}
+		count++
+
+		if withEntries && !ad.IsRm && ad.Entries != nil && ad.Entries != schema.NoEntries {
+			entries := ad.Entries.(cidlink.Link).Cid
+			_, ok := mapped[string(entries.Bytes())+string(ad.ContextID)]
+			if _, done := exported[entries]; ok && !done {
+				exported[entries] = struct{}{}
+				if err = exportEntries(ctx, entriesLsys, ad.Entries); err != nil {
+					return count, fmt.Errorf("cannot export entries %s of advertisement %s: %w", entries, c, err)
+				}
+			}
+		}
+
+		if ad.PreviousID == nil {
+			break
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+	return count, nil
+}
+
+// exportEntries loads every node in the entries DAG at root via the given link system.
+func exportEntries(ctx context.Context, lsys ipld.LinkSystem, root ipld.Link) error {
+	n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, root, basicnode.Prototype.Any)
+	if err != nil {
+		return err
+	}
+	sel, err := selector.CompileSelector(selectorparse.CommonSelector_ExploreAllRecursively)
+	if err != nil {
+		return err
+	}
+	prog := traversal.Progress{
+		Cfg: &traversal.Config{
+			Ctx:                            ctx,
+			LinkSystem:                     lsys,
+			LinkTargetNodePrototypeChooser: basicnode.Chooser,
+			LinkVisitOnlyOnce:              true,
+		},
+	}
+	return prog.WalkAdv(n, sel, func(traversal.Progress, ipld.Node, traversal.VisitReason) error { return nil })
+}
+
+// teeLinkSystem returns a copy of the given link system that also writes every block it reads to
+// the given CAR blockstore.
+func teeLinkSystem(ctx context.Context, lsys ipld.LinkSystem, bs *blockstore.ReadWrite) ipld.LinkSystem {
+	read := lsys.StorageReadOpener
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		r, err := read(lctx, lnk)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		blk, err := blocks.NewBlockWithCid(data, lnk.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, err
+		}
+		if err = bs.Put(ctx, blk); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+	return lsys
+}
+
+// importBatchSize is the maximum number of advertisements, along with the mappings they restore,
+// written to the datastore per batch when importing an advertisement chain.
+var importBatchSize = 1024
+
+// ImportChain seeds the engine with the advertisement chain in the CARv2 file at the given path,
+// as written by Engine.ExportChain. The advertisements are stored and marked as the latest, and
+// the context ID mappings are restored from the latest advertisement of each context ID, so that
+// the engine can continue to publish on top of the imported chain. The latest advertisement is
+// then announced.
+//
+// Every advertisement must be present in the file and carry a valid signature. The latest
+// advertisement must be signed by the key with which the engine would sign it, i.e. the engine's
+// identity or signer; ErrChainSignerMismatch is returned otherwise. Any entries in the file are
+// skipped, since entries are regenerated via the registered multihash lister as needed. The engine
+// must not have published any advertisements; ErrChainExists is returned otherwise.
+//
+// The advertisements and mappings are written in batches of bounded size, and the reference to
+// the latest advertisement is written last, so that the chain only becomes visible once fully
+// imported. Advertisements cannot be published while the chain is imported; calls that publish
+// return ErrChainImporting. The mappings restored so far are removed if the import fails.
+//
+// This function returns the CID of the latest advertisement and the number of advertisements
+// imported.
+func (e *Engine) ImportChain(ctx context.Context, path string) (cid.Cid, int, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+
+	bs, err := blockstore.OpenReadOnly(path, carv2.ZeroLengthSectionAsEOF(true), blockstore.UseWholeCIDs(true))
+	if err != nil {
+		return cid.Undef, 0, fmt.Errorf("cannot open CAR file: %w", err)
+	}
+	defer bs.Close()
+	roots, err := bs.Roots()
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	if len(roots) != 1 {
+		return cid.Undef, 0, fmt.Errorf("CAR file must have exactly one root; got %d", len(roots))
+	}
+	head := roots[0]
+
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		blk, err := bs.Get(lctx.Ctx, lnk.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(blk.RawData()), nil
+	}
+	loadAd := func(c cid.Cid) (*schema.Advertisement, error) {
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		return schema.UnwrapAdvertisement(n)
+	}
+
+	headAd, err := loadAd(head)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	if err = e.checkChainSigner(ctx, headAd); err != nil {
+		return cid.Undef, 0, err
+	}
+
+	if err = e.startImport(ctx); err != nil {
+		return cid.Undef, 0, err
+	}
+	imp := &chainImport{
+		contexts:  make(map[string]importedContext),
+		providers: make(map[peer.ID]struct{}),
+	}
+	count, err := e.importChain(ctx, bs, loadAd, head, imp)
+	if err == nil {
+		err = e.finishImport(ctx, head)
+	}
+	if err != nil {
+		if undoErr := e.undoImport(ctx, imp); undoErr != nil {
+			log.Errorw("Failed to remove the mappings of incomplete chain import", "err", undoErr)
+		}
+		e.endImport()
+		return cid.Undef, 0, err
+	}
+	e.endImport()
+	log.Infow("Imported advertisement chain", "head", head, "count", count, "path", path)
+
+	if err = e.queueAnnounce(ctx, head, count); err != nil {
+		return head, count, err
+	}
+	return head, count, nil
+}
+
+// chainImport tracks the mappings restored by an advertisement chain import.
+type chainImport struct {
+	// contexts is keyed by provider and context ID.
+	contexts  map[string]importedContext
+	providers map[peer.ID]struct{}
+}
+
+// importedContext captures the mapping restored for a context ID, if any.
+type importedContext struct {
+	provider  peer.ID
+	contextID []byte
+	// entries is cid.Undef if the latest advertisement of the context ID is a removal, in which
+	// case no mapping is restored.
+	entries cid.Cid
+}
+
+// checkChainSigner checks that the given latest advertisement of a chain to import is signed by
+// the key with which the engine would sign it.
+func (e *Engine) checkChainSigner(ctx context.Context, ad *schema.Advertisement) error {
+	got, err := ad.VerifySignature()
+	if err != nil {
+		return fmt.Errorf("invalid signature of latest advertisement: %w", err)
+	}
+	// The signer may pick its key by provider; sign a copy to find out which key it would use.
+	probe := *ad
+	probe.Signature = nil
+	if err = e.signer.Sign(ctx, &probe); err != nil {
+		return err
+	}
+	want, err := probe.VerifySignature()
+	if err != nil {
+		return fmt.Errorf("invalid signature by engine signer: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: signed by %s, expected %s", ErrChainSignerMismatch, got, want)
+	}
+	return nil
+}
+
+// startImport checks that the engine has no advertisement chain and marks a chain import as in
+// progress, so that no advertisement is published until it ends.
+func (e *Engine) startImport(ctx context.Context) error {
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+	if e.importing {
+		return ErrChainImporting
+	}
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if latest != cid.Undef {
+		return ErrChainExists
+	}
+	e.importing = true
+	return nil
+}
+
+// finishImport marks the imported chain at head as the latest.
+func (e *Engine) finishImport(ctx context.Context, head cid.Cid) error {
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+	return e.putLatestAdv(ctx, e.ds, head.Bytes())
+}
+
+// endImport marks the chain import as no longer in progress.
+func (e *Engine) endImport() {
+	e.chainLk.Lock()
+	e.importing = false
+	e.chainLk.Unlock()
+}
+
+// importChain stores the advertisements in the chain at head, and restores the mappings they
+// record, in batches of at most importBatchSize advertisements. It returns the number of
+// advertisements stored.
+func (e *Engine) importChain(ctx context.Context, bs *blockstore.ReadOnly, loadAd func(cid.Cid) (*schema.Advertisement, error), head cid.Cid, imp *chainImport) (int, error) {
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count, pending int
+	for c := head; c != cid.Undef; {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		ad, err := loadAd(c)
+		if err != nil {
+			return count, err
+		}
+		if _, err = ad.VerifySignature(); err != nil {
+			return count, fmt.Errorf("invalid signature of advertisement %s: %w", c, err)
+		}
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return count, err
+		}
+		if err = b.Put(ctx, datastore.NewKey(c.String()), blk.RawData()); err != nil {
+			return count, err
+		}
+		if err = e.importMappings(ctx, b, ad, imp); err != nil {
+			return count, fmt.Errorf("cannot restore mappings of advertisement %s: %w", c, err)
+		}
+		count++
+
+		if pending++; pending == importBatchSize {
+			if err = b.Commit(ctx); err != nil {
+				return count, fmt.Errorf("could not commit imported advertisements: %w", err)
+			}
+			if b, err = e.ds.Batch(ctx); err != nil {
+				return count, err
+			}
+			pending = 0
+		}
+
+		if ad.PreviousID == nil {
+			break
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+	if err = b.Commit(ctx); err != nil {
+		return count, fmt.Errorf("could not commit imported advertisements: %w", err)
+	}
+	return count, nil
+}
+
+// importMappings writes the mappings recorded by the given advertisement to w, unless a later
+// advertisement for the same context ID or provider was already imported.
+func (e *Engine) importMappings(ctx context.Context, w datastore.Write, ad *schema.Advertisement, imp *chainImport) error {
+	p, err := peer.Decode(ad.Provider)
+	if err != nil {
+		return err
+	}
+	if _, ok := imp.providers[p]; !ok {
+		imp.providers[p] = struct{}{}
+		if err = e.putProviderAddrsMap(ctx, w, p, ad.Addresses); err != nil {
+			return err
+		}
+	}
+
+	if len(ad.ContextID) == 0 {
+		return nil
+	}
+	key := ad.Provider + "/" + string(ad.ContextID)
+	if _, ok := imp.contexts[key]; ok {
+		return nil
+	}
+	imported := importedContext{provider: p, contextID: ad.ContextID}
+	if !ad.IsRm && ad.Entries != nil && ad.Entries != schema.NoEntries {
+		imported.entries = ad.Entries.(cidlink.Link).Cid
+	}
+	imp.contexts[key] = imported
+	if imported.entries == cid.Undef {
+		return nil
+	}
+	if err = e.putKeyCidMap(ctx, w, p, ad.ContextID, imported.entries); err != nil {
+		return err
+	}
+	return w.Put(ctx, e.keyToMetadataKey(p, ad.ContextID), ad.Metadata)
+}
+
+// undoImport deletes the mappings restored by an incomplete chain import. The advertisements
+// stored are left to be swept by Engine.GC, since they are not reachable.
+func (e *Engine) undoImport(ctx context.Context, imp *chainImport) error {
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for p := range imp.providers {
+		if err = b.Delete(ctx, e.providerAddrsKey(p)); err != nil {
+			return err
+		}
+	}
+	for _, imported := range imp.contexts {
+		if imported.entries == cid.Undef {
+			continue
+		}
+		if err = e.deleteKeyCidMap(ctx, b, imported.provider, imported.contextID); err != nil {
+			return err
+		}
+		if err = e.deleteCidKeyMap(ctx, b, imported.entries); err != nil {
+			return err
+		}
+		if err = e.deleteKeyMetadataMap(ctx, b, imported.provider, imported.contextID); err != nil {
+			return err
+		}
+	}
+	return b.Commit(ctx)
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metrics"
 	"github.com/golang/groupcache/lru"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
@@ -72,6 +74,8 @@ type (
 		lock sync.Mutex
 		// chunker is the underlying chunker that generates a DAG from a provider.MultihashIterator.
 		chunker EntriesChunker
+		// evictionHook, if set, is called with the root of every DAG evicted from the cache.
+		evictionHook func(ipld.Link)
 	}
 
 	// NewChunkerFunc instantiates the core EntriesChunker to use for generating advertisement
@@ -125,6 +129,7 @@ func NewCachedEntriesChunker(ctx context.Context, ds datastore.Batching, capacit
 	ls.lsys.StorageReadOpener = ls.storageReadOpener
 	ls.lsys.StorageWriteOpener = ls.storageWriteOpener
 	ls.cache.OnEvicted = ls.onEvicted
+	metrics.CacheCap.Set(float64(capacity))
 
 	chunker, err := newChunker(&ls.lsys)
 	if err != nil {
@@ -227,9 +232,23 @@ func (ls *CachedEntriesChunker) onEvicted(k lru.Key, val interface{}) {
 	if err != nil {
 		log.Errorw("failed to prune persisted cache key after eviction", "err", err)
 		ls.onEvictedErr = err
+		return
+	}
+	metrics.CacheEvictions.Inc()
+	if ls.evictionHook != nil {
+		ls.evictionHook(chunkRoot)
 	}
 }
 
+// SetEvictionHook sets the function that is called with the root of every DAG evicted from the
+// cache, replacing any previously set hook. The hook is called synchronously while the cache is
+// locked, and must not block or call back into the CachedEntriesChunker.
+func (ls *CachedEntriesChunker) SetEvictionHook(hook func(root ipld.Link)) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.evictionHook = hook
+}
+
 func dsKey(l ipld.Link) datastore.Key {
 	return datastore.NewKey(l.(cidlink.Link).Cid.String())
 }
@@ -263,10 +282,12 @@ func (ls *CachedEntriesChunker) Chunk(ctx context.Context, mhi provider.Multihas
 	}
 
 	// Store the multihashes in mhi as a DAG and get the root link.
+	start := time.Now()
 	root, err := ls.chunker.Chunk(ctx, mhi)
 	if err != nil {
 		return nil, err
 	}
+	metrics.ChunkingDuration.Observe(metrics.Since(start))
 
 	// Store internal mappings for caching purposes.
 	err = ls.performOnCache(ctx, func(cache *lru.Cache) { cache.Add(root, links) })
@@ -288,11 +309,13 @@ func (ls *CachedEntriesChunker) sync(ctx context.Context) error {
 func (ls *CachedEntriesChunker) GetRawCachedChunk(ctx context.Context, l ipld.Link) ([]byte, error) {
 	raw, err := ls.ds.Get(ctx, dsKey(l))
 	if err == datastore.ErrNotFound {
+		metrics.CacheMisses.Inc()
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	metrics.CacheHits.Inc()
 	return raw, nil
 }
 
@@ -339,6 +362,52 @@ func (ls *CachedEntriesChunker) Clear(ctx context.Context) error {
 	return nil
 }
 
+// Roots lists the links to the root of all the DAGs that are currently cached.
+func (ls *CachedEntriesChunker) Roots(ctx context.Context) ([]ipld.Link, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	results, err := ls.ds.Query(ctx, dsq.Query{Prefix: rootKeyPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var roots []ipld.Link
+	for r := range results.Next() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if r.Error != nil {
+			return nil, fmt.Errorf("cannot read cache key: %w", r.Error)
+		}
+		l, err := ls.linkFromDsCachePrefixedKey(datastore.RawKey(r.Key))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, l)
+	}
+	return roots, nil
+}
+
+// Evict removes the DAG with the given root from the cache, deleting the chunks that are not shared
+// with any other cached DAG. This function returns false if no DAG with the given root is cached.
+func (ls *CachedEntriesChunker) Evict(ctx context.Context, root ipld.Link) (bool, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	var found bool
+	err := ls.performOnCache(ctx, func(cache *lru.Cache) {
+		if _, found = cache.Get(root); found {
+			cache.Remove(root)
+		}
+	})
+	if err != nil || !found {
+		return found, err
+	}
+	return true, ls.sync(ctx)
+}
+
 // Close syncs the backing datastore but does not close it.
 // This is because cached entries chunker wraps an existing datastore and does
 // not construct it, and the wrapped datastore may be in use elsewhere.
@@ -467,6 +536,7 @@ func (ls *CachedEntriesChunker) performOnCache(ctx context.Context, action func(
 		ls.onEvictedErr = nil
 	}()
 	action(ls.cache)
+	metrics.CacheLen.Set(float64(ls.cache.Len()))
 	err := ls.onEvictedErr
 	return err
 }
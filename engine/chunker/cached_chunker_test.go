@@ -302,6 +302,44 @@ func TestCachedEntriesChunker_OverlappingDagIsNotEvicted(t *testing.T) {
 	require.Equal(t, 1, subject.Len())
 }
 
+func TestCachedEntriesChunker_EvictRetainsOverlappingChunks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := chunker.NewCachedEntriesChunker(ctx, store, 10, chunker.NewChainChunkerFunc(10), false)
+	require.NoError(t, err)
+	defer subject.Close()
+
+	c1Mhs := testutil.RandomMultihashes(t, rng, 10)
+	c1Lnk, err := subject.Chunk(ctx, provider.SliceMultihashIterator(c1Mhs))
+	require.NoError(t, err)
+	c2Mhs := append(c1Mhs, testutil.RandomMultihashes(t, rng, 10)...)
+	c2Lnk, err := subject.Chunk(ctx, provider.SliceMultihashIterator(c2Mhs))
+	require.NoError(t, err)
+
+	roots, err := subject.Roots(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ipld.Link{c1Lnk, c2Lnk}, roots)
+
+	// Assert evicting the larger DAG retains the chunk it shares with the smaller one.
+	evicted, err := subject.Evict(ctx, c2Lnk)
+	require.NoError(t, err)
+	require.True(t, evicted)
+	requireChunkIsNotCached(t, subject, c2Lnk)
+	requireChunkIsCached(t, subject, c1Lnk)
+	require.Equal(t, 1, subject.Len())
+
+	roots, err = subject.Roots(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []ipld.Link{c1Lnk}, roots)
+
+	evicted, err = subject.Evict(ctx, c2Lnk)
+	require.NoError(t, err)
+	require.False(t, evicted)
+}
+
 func testCachedEntriesChunker_RecoversFromCorruptCacheGracefully(t *testing.T, capacity int, c chunker.NewChunkerFunc) {
 	rng := rand.New(rand.NewSource(1413))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
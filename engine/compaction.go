@@ -0,0 +1,393 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const retiredChainPrefix = "sync/retired/"
+
+var (
+	// ErrChainChanged signals that the advertisement chain changed while it was being compacted.
+	ErrChainChanged = errors.New("advertisement chain changed during compaction")
+	// ErrIncompleteChain signals that an advertisement in the chain is missing from the local
+	// datastore, and so the chain cannot be walked back to its first advertisement.
+	ErrIncompleteChain = errors.New("advertisement chain is incomplete")
+)
+
+// retiredChain records the head of an advertisement chain that was replaced by compaction.
+type retiredChain struct {
+	Head      cid.Cid   `json:"h"`
+	RetiredAt time.Time `json:"t"`
+}
+
+// liveContext captures a context ID that is currently advertised by a provider.
+type liveContext struct {
+	provider  peer.ID
+	contextID []byte
+	entries   cid.Cid
+}
+
+// Compact replaces the advertisement chain with a new chain that contains exactly one
+// advertisement per context ID currently advertised, i.e. without the advertisements that were
+// superseded or removed. This reduces the number of advertisements that new indexers must walk in
+// order to ingest the content advertised by long-lived providers.
+//
+// The new chain is derived from the internal context ID to entries and metadata mappings, and
+// is signed by the engine's signer. Once the new chain is fully stored, it is atomically marked as
+// the latest and announced. The replaced chain remains readable for the grace period configured
+// via WithCompactionGracePeriod, after which it is deleted by subsequent compactions.
+//
+// The advertisements of the new chain are neither counted as published nor reported via
+// AdStoredEvent, since they restate advertisements that were already published; a single
+// ChainCompactedEvent is emitted instead.
+//
+// ErrChainChanged is returned if an advertisement is published during compaction, in which case
+// the compaction should be retried. provider.ErrContextIDNotFound is returned if there are no
+// context IDs currently advertised.
+//
+// This function returns the CID of the head of the new chain and the number of advertisements in
+// it.
+func (e *Engine) Compact(ctx context.Context) (cid.Cid, int, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+
+	prevHead, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, 0, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+
+	var lives []liveContext
+	err = e.forEachKeyCidMapping(ctx, func(p peer.ID, contextID []byte, c cid.Cid) error {
+		lives = append(lives, liveContext{provider: p, contextID: contextID, entries: c})
+		return nil
+	})
+	if err != nil {
+		return cid.Undef, 0, fmt.Errorf("could not list context IDs: %w", err)
+	}
+	if len(lives) == 0 {
+		return cid.Undef, 0, provider.ErrContextIDNotFound
+	}
+	log := log.With("prevHead", prevHead, "count", len(lives))
+	log.Info("Compacting advertisement chain")
+
+	fallback := &chainFallback{e: e, head: prevHead}
+	var head cid.Cid
+	for _, live := range lives {
+		adv, err := e.generateCompactedAdv(ctx, live, fallback)
+		if err != nil {
+			return cid.Undef, 0, fmt.Errorf("failed to generate advertisement for context ID %s: %w",
+				base64.StdEncoding.EncodeToString(live.contextID), err)
+		}
+		if head != cid.Undef {
+			adv.PreviousID = cidlink.Link{Cid: head}
+		}
+		if err = e.signer.Sign(ctx, adv); err != nil {
+			return cid.Undef, 0, err
+		}
+		// The advertisements are not counted as published, since they only restate the ones
+		// already published.
+		if head, err = e.storeAdv(ctx, e.ds, *adv); err != nil {
+			return cid.Undef, 0, err
+		}
+	}
+
+	if err = e.replaceChain(ctx, prevHead, head); err != nil {
+		return cid.Undef, 0, err
+	}
+	log.Infow("Compacted advertisement chain", "head", head)
+	e.emit(ChainCompactedEvent{Head: head, PrevHead: prevHead, Count: len(lives)})
+
+	if err = e.pruneRetiredChains(ctx); err != nil {
+		log.Warnw("Failed to delete retired advertisement chains", "err", err)
+	}
+	if err = e.queueAnnounce(ctx, head, len(lives)); err != nil {
+		return head, len(lives), err
+	}
+	return head, len(lives), nil
+}
+
+// replaceChain marks head as the latest advertisement in place of prevHead, and records the chain
+// at prevHead as retired. ErrChainChanged is returned if prevHead is no longer the latest.
+func (e *Engine) replaceChain(ctx context.Context, prevHead, head cid.Cid) error {
+	// Hold the chain lock so that no advertisement is appended to the previous chain in between.
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+
+	if e.importing {
+		return ErrChainImporting
+	}
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if latest != prevHead {
+		return ErrChainChanged
+	}
+	if prevHead != cid.Undef && prevHead != head {
+		if err = e.putRetiredChain(ctx, retiredChain{Head: prevHead, RetiredAt: time.Now()}); err != nil {
+			return fmt.Errorf("failed to record retired chain: %w", err)
+		}
+	}
+	if err = e.putLatestAdv(ctx, e.ds, head.Bytes()); err != nil {
+		return fmt.Errorf("failed to update reference to latest advertisement: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) generateCompactedAdv(ctx context.Context, live liveContext, fallback *chainFallback) (*schema.Advertisement, error) {
+	md, err := e.getKeyMetadataMap(ctx, live.provider, live.contextID)
+	if err == datastore.ErrNotFound {
+		md, err = fallback.metadata(ctx, live.provider, live.contextID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get metadata: %w", err)
+	}
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := e.getProviderAddrsMap(ctx, live.provider)
+	if err == datastore.ErrNotFound {
+		if live.provider == e.provider.ID {
+			_, defaultAddrs := e.resolveProvider(nil)
+			addrs = make([]string, 0, len(defaultAddrs))
+			for _, addr := range defaultAddrs {
+				addrs = append(addrs, addr.String())
+			}
+			err = nil
+		} else {
+			addrs, err = fallback.addrs(ctx, live.provider)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get provider addresses: %w", err)
+	}
+
+	return &schema.Advertisement{
+		Provider:  live.provider.String(),
+		Addresses: addrs,
+		Entries:   cidlink.Link{Cid: live.entries},
+		ContextID: live.contextID,
+		Metadata:  mdBytes,
+	}, nil
+}
+
+// chainFallback looks up the metadata and provider addresses that are not captured by the
+// internal mappings, e.g. for datastores that predate them, from the latest advertisements in the
+// chain. The chain is walked at most once, and only if needed.
+type chainFallback struct {
+	e      *Engine
+	head   cid.Cid
+	walked bool
+	mds    map[string][]byte
+	addrsm map[peer.ID][]string
+}
+
+func (f *chainFallback) metadata(ctx context.Context, p peer.ID, contextID []byte) (metadata.Metadata, error) {
+	if err := f.walk(ctx); err != nil {
+		return metadata.Metadata{}, err
+	}
+	data, ok := f.mds[p.String()+"/"+string(contextID)]
+	if !ok {
+		return metadata.Metadata{}, datastore.ErrNotFound
+	}
+	var md metadata.Metadata
+	if err := md.UnmarshalBinary(data); err != nil {
+		return metadata.Metadata{}, err
+	}
+	return md, nil
+}
+
+func (f *chainFallback) addrs(ctx context.Context, p peer.ID) ([]string, error) {
+	if err := f.walk(ctx); err != nil {
+		return nil, err
+	}
+	addrs, ok := f.addrsm[p]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return addrs, nil
+}
+
+func (f *chainFallback) walk(ctx context.Context) error {
+	if f.walked {
+		return nil
+	}
+	f.mds = make(map[string][]byte)
+	f.addrsm = make(map[peer.ID][]string)
+	err := f.e.walkChain(ctx, f.head, func(_ cid.Cid, ad *schema.Advertisement) (bool, error) {
+		if ad.IsRm {
+			return true, nil
+		}
+		p, err := peer.Decode(ad.Provider)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := f.addrsm[p]; !ok {
+			f.addrsm[p] = ad.Addresses
+		}
+		if len(ad.ContextID) != 0 {
+			key := ad.Provider + "/" + string(ad.ContextID)
+			if _, ok := f.mds[key]; !ok {
+				f.mds[key] = ad.Metadata
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	f.walked = true
+	return nil
+}
+
+// walkChain calls fn for every advertisement in the chain starting from the given head, in
+// reverse order of publication. The walk stops when fn returns false, or when an advertisement
+// is not found in the local datastore.
+func (e *Engine) walkChain(ctx context.Context, head cid.Cid, fn func(cid.Cid, *schema.Advertisement) (bool, error)) error {
+	lsys := e.vanillaLinkSystem()
+	for c := head; c != cid.Undef; {
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				return nil
+			}
+			return fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return err
+		}
+		next, err := fn(c, ad)
+		if err != nil || !next {
+			return err
+		}
+		if ad.PreviousID == nil {
+			return nil
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+	return nil
+}
+
+// walkCompleteChain is like walkChain, except that ErrIncompleteChain is returned if an
+// advertisement is not found before the walk reaches the first advertisement of the chain, unless
+// fn stopped the walk.
+func (e *Engine) walkCompleteChain(ctx context.Context, head cid.Cid, fn func(cid.Cid, *schema.Advertisement) (bool, error)) error {
+	missing := head
+	err := e.walkChain(ctx, head, func(c cid.Cid, ad *schema.Advertisement) (bool, error) {
+		next, err := fn(c, ad)
+		missing = cid.Undef
+		if next && ad.PreviousID != nil {
+			missing = ad.PreviousID.(cidlink.Link).Cid
+		}
+		return next, err
+	})
+	if err != nil {
+		return err
+	}
+	if missing != cid.Undef {
+		return fmt.Errorf("%w: advertisement %s not found", ErrIncompleteChain, missing)
+	}
+	return nil
+}
+
+func (e *Engine) putRetiredChain(ctx context.Context, rc retiredChain) error {
+	data, err := json.Marshal(&rc)
+	if err != nil {
+		return err
+	}
+	return e.ds.Put(ctx, datastore.NewKey(retiredChainPrefix+rc.Head.String()), data)
+}
+
+// forEachRetiredChain calls fn for every chain retired by compaction along with the datastore key
+// at which it is recorded.
+func (e *Engine) forEachRetiredChain(ctx context.Context, fn func(datastore.Key, retiredChain) error) error {
+	results, err := e.ds.Query(ctx, query.Query{Prefix: retiredChainPrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var rc retiredChain
+		if err := json.Unmarshal(r.Value, &rc); err != nil {
+			return err
+		}
+		if err := fn(datastore.RawKey(r.Key), rc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneRetiredChains deletes the advertisements of chains retired by compaction for longer than
+// the configured grace period, except the ones that are also part of the current chain.
+func (e *Engine) pruneRetiredChains(ctx context.Context) error {
+	var expired []retiredChain
+	var expiredKeys []datastore.Key
+	err := e.forEachRetiredChain(ctx, func(k datastore.Key, rc retiredChain) error {
+		if time.Since(rc.RetiredAt) >= e.compactionGracePeriod {
+			expired = append(expired, rc)
+			expiredKeys = append(expiredKeys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[cid.Cid]struct{})
+	err = e.walkChain(ctx, head, func(c cid.Cid, _ *schema.Advertisement) (bool, error) {
+		current[c] = struct{}{}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, rc := range expired {
+		var deleted int
+		err := e.walkChain(ctx, rc.Head, func(c cid.Cid, _ *schema.Advertisement) (bool, error) {
+			// Advertisements are hash-linked; if this one is in the current chain so are the rest.
+			if _, ok := current[c]; ok {
+				return false, nil
+			}
+			deleted++
+			return true, e.ds.Delete(ctx, datastore.NewKey(c.String()))
+		})
+		if err != nil {
+			return err
+		}
+		if err := e.ds.Delete(ctx, expiredKeys[i]); err != nil {
+			return err
+		}
+		log.Infow("Deleted retired advertisement chain", "head", rc.Head, "retiredAt", rc.RetiredAt, "deleted", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// advertisedContext captures an advertisement in the chain for a context ID.
+type advertisedContext struct {
+	provider  string
+	contextID []byte
+	adCid     cid.Cid
+	entries   cid.Cid
+	metadata  []byte
+	isRm      bool
+}
+
+// checkConsistency reports, and repairs if enabled, the internal mappings left half-applied by a
+// crash part way through publishing an advertisement, e.g. by an earlier version of the engine that
+// did not write the mappings and the advertisement in a single batch, or on a datastore that does
+// not apply batches atomically.
+//
+// The mappings of every context ID are compared with the latest advertisement in the chain for the
+// same context ID and entries:
+//  - mappings with no such advertisement are deleted, since that advertisement was never stored;
+//  - metadata and reverse entries CID mappings that are missing or differ from the advertisement
+//    are restored from it.
+//
+// Advertisements that are the latest for their context ID and are not removals, but whose entries
+// are not mapped, are only reported; these are left by a removal advertisement that was never
+// stored, and removing the context ID requires publishing a new advertisement.
+//
+// The check is skipped if the chain cannot be walked back to its first advertisement, since the
+// advertisements of mappings beyond the missing one cannot be told apart from ones never stored.
+//
+// See: WithConsistencyCheck, WithConsistencyRepair.
+func (e *Engine) checkConsistency(ctx context.Context) error {
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	repair := e.consistencyRepair
+	log := log.With("head", head, "repair", repair)
+	log.Info("Checking consistency of advertisement mappings")
+
+	// The latest advertisement per entries and context ID, and per provider and context ID.
+	puts := make(map[string]*advertisedContext)
+	latest := make(map[string]*advertisedContext)
+	err = e.walkCompleteChain(ctx, head, func(c cid.Cid, ad *schema.Advertisement) (bool, error) {
+		if len(ad.ContextID) == 0 {
+			return true, nil
+		}
+		ac := &advertisedContext{
+			provider:  ad.Provider,
+			contextID: ad.ContextID,
+			adCid:     c,
+			metadata:  ad.Metadata,
+			isRm:      ad.IsRm,
+		}
+		if ad.Entries != nil && ad.Entries != schema.NoEntries {
+			ac.entries = ad.Entries.(cidlink.Link).Cid
+		}
+		if _, ok := latest[ad.Provider+"/"+string(ad.ContextID)]; !ok {
+			latest[ad.Provider+"/"+string(ad.ContextID)] = ac
+		}
+		if !ac.isRm && ac.entries != cid.Undef {
+			if _, ok := puts[string(ac.entries.Bytes())+string(ad.ContextID)]; !ok {
+				puts[string(ac.entries.Bytes())+string(ad.ContextID)] = ac
+			}
+		}
+		return true, nil
+	})
+	if errors.Is(err, ErrIncompleteChain) {
+		log.Errorw("Skipped consistency check of advertisement mappings; verify the advertisement chain", "err", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not walk advertisement chain: %w", err)
+	}
+
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	var mapped, found int
+	mappedEntries := make(map[cid.Cid]struct{})
+	err = e.forEachKeyCidMapping(ctx, func(p peer.ID, contextID []byte, c cid.Cid) error {
+		mapped++
+		mappedEntries[c] = struct{}{}
+		log := log.With("providerID", p, "contextID", base64.StdEncoding.EncodeToString(contextID), "entries", c)
+
+		ac, ok := puts[string(c.Bytes())+string(contextID)]
+		if !ok {
+			found++
+			if !repair {
+				log.Warn("Found mappings of context ID whose advertisement was never stored")
+				return nil
+			}
+			log.Warn("Deleting mappings of context ID whose advertisement was never stored")
+			return e.deleteMappings(ctx, b, p, contextID, c)
+		}
+
+		md, err := e.ds.Get(ctx, e.keyToMetadataKey(p, contextID))
+		if err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+		if !bytes.Equal(md, ac.metadata) {
+			found++
+			if !repair {
+				log.Warnw("Found metadata mapping of context ID that differs from its latest advertisement", "adCid", ac.adCid)
+			} else {
+				log.Warnw("Restoring metadata mapping of context ID from its latest advertisement", "adCid", ac.adCid)
+				if err := b.Put(ctx, e.keyToMetadataKey(p, contextID), ac.metadata); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = e.getCidKeyMap(ctx, c)
+		if err == datastore.ErrNotFound {
+			found++
+			if !repair {
+				log.Warnw("Found missing entries mapping of context ID", "adCid", ac.adCid)
+				return nil
+			}
+			log.Warnw("Restoring entries mapping of context ID from its latest advertisement", "adCid", ac.adCid)
+			return e.putKeyCidMap(ctx, b, p, contextID, c)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not check context ID mappings: %w", err)
+	}
+	if repair {
+		if err = b.Commit(ctx); err != nil {
+			return fmt.Errorf("could not commit repaired mappings: %w", err)
+		}
+	}
+
+	var reported int
+	for _, ac := range latest {
+		if _, ok := mappedEntries[ac.entries]; ok || ac.isRm || ac.entries == cid.Undef {
+			continue
+		}
+		log.Errorw("Advertised context ID has no mappings; its removal advertisement may not have been stored",
+			"providerID", ac.provider, "contextID", base64.StdEncoding.EncodeToString(ac.contextID), "adCid", ac.adCid)
+		reported++
+	}
+	if found != 0 && !repair {
+		log.Warnw("Found inconsistent advertisement mappings; enable repair to fix them", "found", found)
+	}
+	log.Infow("Checked consistency of advertisement mappings", "contextIDs", mapped, "found", found, "reported", reported)
+	return nil
+}
+
+// deleteMappings writes the deletion of the mappings of the given provider and context ID to w. The
+// reverse mapping from the entries CID is only deleted if it refers to the given provider and
+// context ID, since different context IDs may share the same entries.
+func (e *Engine) deleteMappings(ctx context.Context, w datastore.Write, p peer.ID, contextID []byte, c cid.Cid) error {
+	if err := e.deleteKeyCidMap(ctx, w, p, contextID); err != nil {
+		return err
+	}
+	if err := e.deleteKeyMetadataMap(ctx, w, p, contextID); err != nil {
+		return err
+	}
+	pAndC, err := e.getCidKeyMap(ctx, c)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	mp := e.provider.ID
+	if len(pAndC.Provider) != 0 {
+		if mp, err = peer.IDFromBytes(pAndC.Provider); err != nil {
+			return err
+		}
+	}
+	if mp != p || !bytes.Equal(pAndC.ContextID, contextID) {
+		return nil
+	}
+	return e.deleteCidKeyMap(ctx, w, c)
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ErrInvalidCursor signals that a cursor given to Engine.ListContextIDsFrom was not returned by
+// ContextIDIterator.Cursor.
+var ErrInvalidCursor = errors.New("invalid context ID cursor")
+
+// ContextIDIterator iterates over the context IDs currently advertised for a provider.
+//
+// See: Engine.ListContextIDs.
+type ContextIDIterator struct {
+	ctx      context.Context
+	e        *Engine
+	provider peer.ID
+	results  query.Results
+	cursor   string
+}
+
+// ListContextIDs returns an iterator over the context IDs currently advertised for the given
+// provider, i.e. those that are mapped to an entries CID and not removed, where an empty provider
+// refers to the engine's default provider.
+//
+// Context IDs are read from the datastore as the iterator advances, in the order of the datastore
+// keys at which they are recorded, and so the order is the same across calls for the same set of
+// context IDs. The iterator must be closed once no longer needed.
+//
+// To resume listing from where a previous iterator left off, see Engine.ListContextIDsFrom.
+func (e *Engine) ListContextIDs(ctx context.Context, p peer.ID) (*ContextIDIterator, error) {
+	return e.ListContextIDsFrom(ctx, p, "")
+}
+
+// ListContextIDsFrom is like Engine.ListContextIDs, except that the iterator only lists the context
+// IDs that come after the given cursor, as returned by ContextIDIterator.Cursor. An empty cursor
+// lists all context IDs.
+//
+// Since the cursor identifies a position in the order of the context IDs rather than an index,
+// context IDs that are added or removed before the cursor do not shift the context IDs listed
+// after it.
+func (e *Engine) ListContextIDsFrom(ctx context.Context, p peer.ID, cursor string) (*ContextIDIterator, error) {
+	if p == "" {
+		p = e.options.provider.ID
+	}
+	q := query.Query{
+		Prefix: keyToCidMapPrefix,
+		Orders: []query.Order{query.OrderByKey{}},
+	}
+	if cursor != "" {
+		// The cursor encodes the datastore key of the context ID last listed, which holds the raw
+		// bytes of the context ID and so may not be valid UTF-8.
+		from, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || !strings.HasPrefix(string(from), "/"+keyToCidMapPrefix) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+		}
+		q.Filters = append(q.Filters, query.FilterKeyCompare{Op: query.GreaterThan, Key: string(from)})
+	}
+	if p != e.options.provider.ID {
+		// The context IDs of providers other than the default one are keyed with their ID as
+		// suffix; skip the rest without looking up their reverse mapping.
+		q.Filters = append(q.Filters, keySuffixFilter("/"+p.String()))
+	}
+	results, err := e.ds.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return &ContextIDIterator{
+		ctx:      ctx,
+		e:        e,
+		provider: p,
+		results:  results,
+		cursor:   cursor,
+	}, nil
+}
+
+// Next returns the next context ID along with the CID of the root of the entries advertised for
+// it. io.EOF is returned once there are no more context IDs.
+func (it *ContextIDIterator) Next() ([]byte, cid.Cid, error) {
+	for {
+		if err := it.ctx.Err(); err != nil {
+			return nil, cid.Undef, err
+		}
+		r, ok := it.results.NextSync()
+		if !ok {
+			return nil, cid.Undef, io.EOF
+		}
+		if r.Error != nil {
+			return nil, cid.Undef, fmt.Errorf("cannot read provider + context id to entries cid mapping: %w", r.Error)
+		}
+		_, c, err := cid.CidFromBytes(r.Value)
+		if err != nil {
+			return nil, cid.Undef, err
+		}
+		p, contextID, err := it.e.providerAndContextFromKeyCidKey(it.ctx, datastore.RawKey(r.Key), c)
+		if err != nil {
+			return nil, cid.Undef, err
+		}
+		if p == it.provider {
+			it.cursor = base64.RawURLEncoding.EncodeToString([]byte(r.Key))
+			return contextID, c, nil
+		}
+	}
+}
+
+// Cursor returns the opaque position of the context ID last returned by Next, from which a later
+// listing can resume via Engine.ListContextIDsFrom. The cursor is URL-safe base64 encoded. The
+// cursor given to Engine.ListContextIDsFrom is returned if Next has not returned any context IDs
+// yet.
+func (it *ContextIDIterator) Cursor() string {
+	return it.cursor
+}
+
+// Close releases the resources held by the iterator.
+func (it *ContextIDIterator) Close() error {
+	return it.results.Close()
+}
+
+// keySuffixFilter is a query.Filter that matches the entries with keys ending in the suffix.
+type keySuffixFilter string
+
+func (f keySuffixFilter) Filter(e query.Entry) bool {
+	return strings.HasSuffix(e.Key, string(f))
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// contextLocker serializes the publication of advertisements for the same provider and context ID,
+// so that concurrent puts and removes of a context ID cannot both act on the same mappings. The
+// zero value is ready to use.
+type contextLocker struct {
+	lk    sync.Mutex
+	locks map[string]*contextLock
+}
+
+// contextLock is a lock on a provider and context ID, along with the number of callers holding or
+// waiting for it.
+type contextLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the given provider and context ID, and returns the function that unlocks it.
+func (l *contextLocker) lock(p peer.ID, contextID []byte) func() {
+	key := string(p) + "/" + string(contextID)
+	l.lk.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*contextLock)
+	}
+	cl, ok := l.locks[key]
+	if !ok {
+		cl = &contextLock{}
+		l.locks[key] = cl
+	}
+	cl.refs++
+	l.lk.Unlock()
+
+	cl.Lock()
+	return func() {
+		cl.Unlock()
+		l.lk.Lock()
+		defer l.lk.Unlock()
+		if cl.refs--; cl.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
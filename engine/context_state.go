@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ContextState captures the state of a context ID as recorded by the engine.
+//
+// See: Engine.GetContextState.
+type ContextState struct {
+	// Provider is the ID of the provider the context ID belongs to.
+	Provider peer.ID
+	// ContextID is the context ID.
+	ContextID []byte
+	// EntriesCid is the CID of the root of the entries DAG advertised for the context ID, or
+	// cid.Undef if the context ID is removed.
+	EntriesCid cid.Cid
+	// Metadata is the current metadata of the context ID, or empty if the context ID is removed.
+	Metadata metadata.Metadata
+	// IsRm signals whether the context ID is removed.
+	IsRm bool
+	// LastAdCid is the CID of the latest advertisement in the chain for the context ID, or
+	// cid.Undef if no such advertisement is found.
+	LastAdCid cid.Cid
+}
+
+// GetContextState returns the state of the given context ID for the given provider, where an
+// empty provider refers to the engine's default provider.
+//
+// The last advertisement for the context ID is found by walking the advertisement chain from the
+// latest advertisement, and so takes time proportional to the length of the chain. A removed
+// context ID is only known as long as its removal advertisement remains in the chain; chain
+// compaction drops removal advertisements. provider.ErrContextIDNotFound is returned if the
+// context ID is neither currently advertised nor found in the chain.
+func (e *Engine) GetContextState(ctx context.Context, p peer.ID, contextID []byte) (*ContextState, error) {
+	if p == "" {
+		p = e.options.provider.ID
+	}
+	state := &ContextState{
+		Provider:  p,
+		ContextID: contextID,
+	}
+
+	var err error
+	state.EntriesCid, err = e.getKeyCidMap(ctx, p, contextID)
+	if err != nil && err != datastore.ErrNotFound {
+		return nil, fmt.Errorf("could not get entries cid by provider + context id: %w", err)
+	}
+	if state.EntriesCid != cid.Undef {
+		state.Metadata, err = e.getKeyMetadataMap(ctx, p, contextID)
+		if err != nil && err != datastore.ErrNotFound {
+			return nil, fmt.Errorf("could not get metadata for provider + context id: %w", err)
+		}
+	}
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	err = e.walkChain(ctx, head, func(c cid.Cid, ad *schema.Advertisement) (bool, error) {
+		if ad.Provider != p.String() || !bytes.Equal(ad.ContextID, contextID) {
+			return true, nil
+		}
+		state.LastAdCid = c
+		state.IsRm = ad.IsRm
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The mappings of a context ID are deleted when it is removed, so a context ID with no entries
+	// CID is only known if its removal advertisement is found.
+	if state.EntriesCid == cid.Undef && !state.IsRm {
+		return nil, provider.ErrContextIDNotFound
+	}
+	return state, nil
+}
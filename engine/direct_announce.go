@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"net/url"
+)
+
+// AnnounceURLs returns the indexer URLs to which direct HTTP announcements are sent.
+//
+// See: WithDirectAnnounce.
+func (e *Engine) AnnounceURLs() []*url.URL {
+	e.announceURLsLk.RLock()
+	defer e.announceURLsLk.RUnlock()
+	urls := make([]*url.URL, len(e.announceURLs))
+	copy(urls, e.announceURLs)
+	return urls
+}
+
+// AddAnnounceURL adds the given indexer URL to the ones to which direct HTTP announcements are
+// sent, starting from the next announcement. Returns false if the URL is already present.
+func (e *Engine) AddAnnounceURL(u *url.URL) bool {
+	e.announceURLsLk.Lock()
+	defer e.announceURLsLk.Unlock()
+	for _, existing := range e.announceURLs {
+		if existing.String() == u.String() {
+			return false
+		}
+	}
+	e.announceURLs = append(e.announceURLs, u)
+	log.Infow("Added direct announce URL", "url", u)
+	return true
+}
+
+// RemoveAnnounceURL removes the given indexer URL from the ones to which direct HTTP
+// announcements are sent, and discards any announcement to it that is queued for retry. Returns
+// false if the URL is not present.
+func (e *Engine) RemoveAnnounceURL(ctx context.Context, u *url.URL) (bool, error) {
+	e.announceURLsLk.Lock()
+	defer e.announceURLsLk.Unlock()
+	for i, existing := range e.announceURLs {
+		if existing.String() != u.String() {
+			continue
+		}
+		urls := make([]*url.URL, 0, len(e.announceURLs)-1)
+		urls = append(urls, e.announceURLs[:i]...)
+		e.announceURLs = append(urls, e.announceURLs[i+1:]...)
+		log.Infow("Removed direct announce URL", "url", u)
+
+		e.retryLk.Lock()
+		defer e.retryLk.Unlock()
+		return true, e.ds.Delete(ctx, announceRetryKey(u.String()))
+	}
+	return false, nil
+}
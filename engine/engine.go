@@ -1,25 +1,32 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-legs"
 	"github.com/filecoin-project/go-legs/dtsync"
 	"github.com/filecoin-project/go-legs/httpsync"
 	provider "github.com/filecoin-project/index-provider"
 	"github.com/filecoin-project/index-provider/engine/chunker"
+	"github.com/filecoin-project/index-provider/engine/policy"
 	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/metrics"
 	httpclient "github.com/filecoin-project/storetheindex/api/v0/ingest/client/http"
 	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	dsn "github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
@@ -32,6 +39,7 @@ const (
 	cidToKeyMapPrefix            = "map/cidKey/"
 	cidToProviderAndKeyMapPrefix = "map/cidProvAndKey/"
 	keyToMetadataMapPrefix       = "map/keyMD/"
+	providerToAddrsMapPrefix     = "map/provAddrs/"
 	latestAdvKey                 = "sync/adv/"
 	linksCachePath               = "/cache/links"
 )
@@ -50,9 +58,46 @@ type Engine struct {
 	entriesChunker *chunker.CachedEntriesChunker
 
 	publisher legs.Publisher
+	announcer *announcer
 
 	mhLister provider.MultihashLister
 	cblk     sync.Mutex
+
+	// provLk synchronizes access to the default provider addresses, which may change at runtime.
+	// See: Engine.UpdateProviderAddrs.
+	provLk sync.RWMutex
+	// gcLk excludes writes to the datastore that are yet to be reachable from the latest
+	// advertisement or the context ID mappings while garbage is collected. See: Engine.GC.
+	gcLk sync.RWMutex
+	// chainLk serializes appends to the advertisement chain, i.e. reading the latest advertisement,
+	// storing a new advertisement that links to it and marking the new one as the latest, so that
+	// concurrent publishers cannot fork the chain.
+	chainLk sync.Mutex
+	// importing is set while an advertisement chain is imported, during which no advertisement is
+	// published. Guarded by chainLk. See: Engine.ImportChain.
+	importing bool
+	// rootLk serializes updates to the root of the publisher, so that it never regresses to an
+	// advertisement older than the latest. See: Engine.announce.
+	rootLk sync.Mutex
+	// contextLks serializes the publication of advertisements for the same provider and context
+	// ID, from reading their mappings until the advertisement is stored.
+	contextLks contextLocker
+
+	// events distributes the events emitted by the engine to subscribers. See: Engine.Subscribe.
+	events eventBus
+	// dtUnsub unsubscribes from the events of the data transfer manager, if any.
+	dtUnsub func()
+	// syncs records the advertisements and entries synced by indexers. See: Engine.SyncStatus.
+	syncs syncTracker
+
+	// announceURLsLk synchronizes access to the direct announce URLs, which may change at runtime.
+	// See: Engine.AddAnnounceURL.
+	announceURLsLk sync.RWMutex
+	// retryLk synchronizes access to the queue of direct HTTP announcements to retry.
+	// See: Engine.AnnounceRetries.
+	retryLk     sync.Mutex
+	retryCancel context.CancelFunc
+	retryDone   chan struct{}
 }
 
 var _ provider.Interface = (*Engine)(nil)
@@ -69,10 +114,11 @@ var _ provider.Interface = (*Engine)(nil)
 //   - https://github.com/filecoin-project/storetheindex
 //   - https://github.com/filecoin-project/go-legs
 //
-// Published advertisements are signed using the given private key. The
-// retAddrs corresponds to the endpoints at which the data block associated to
-// the advertised multihashes can be retrieved. If no retAddrs are specified,
-// then use the listen addresses of the given libp2p host.
+// Published advertisements are signed using the given signer, or the private
+// key of the libp2p host if none is given; see WithSigner. The retAddrs
+// corresponds to the endpoints at which the data block associated to the
+// advertised multihashes can be retrieved. If no retAddrs are specified, then
+// use the listen addresses of the given libp2p host.
 //
 // The engine also provides the ability to generate advertisements via
 // Engine.NotifyPut and Engine.NotifyRemove as long as a
@@ -92,6 +138,9 @@ func New(o ...Option) (*Engine, error) {
 	}
 
 	e.lsys = e.mkLinkSystem()
+	if e.announceWindow > 0 || e.announceCount > 0 {
+		e.announcer = newAnnouncer(e.announceWindow, e.announceCount, e.announce)
+	}
 
 	return e, nil
 }
@@ -105,11 +154,28 @@ func New(o ...Option) (*Engine, error) {
 func (e *Engine) Start(ctx context.Context) error {
 	var err error
 	// Create datastore entriesChunker.
-	entriesCacheDs := dsn.Wrap(e.ds, datastore.NewKey(linksCachePath))
+	entriesCacheDs := e.entCacheDs
+	if entriesCacheDs == nil {
+		entriesCacheDs = dsn.Wrap(e.ds, datastore.NewKey(linksCachePath))
+	}
 	e.entriesChunker, err = chunker.NewCachedEntriesChunker(ctx, entriesCacheDs, e.entCacheCap, e.chunker, e.purgeCache)
 	if err != nil {
 		return err
 	}
+	e.entriesChunker.SetEvictionHook(func(root ipld.Link) {
+		e.emit(CacheEvictedEvent{Root: root.(cidlink.Link).Cid})
+	})
+
+	handOverAd, err := e.handOver(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to hand over to default provider: %w", err)
+	}
+
+	if e.consistencyCheck || e.consistencyRepair {
+		if err = e.checkConsistency(ctx); err != nil {
+			return fmt.Errorf("failed to check consistency of advertisement mappings: %w", err)
+		}
+	}
 
 	e.publisher, err = e.newPublisher()
 	if err != nil {
@@ -117,6 +183,10 @@ func (e *Engine) Start(ctx context.Context) error {
 		return err
 	}
 
+	if e.pubKind == DataTransferPublisher && e.pubDT != nil {
+		e.dtUnsub = e.pubDT.SubscribeToEvents(e.onDataTransferEvent)
+	}
+
 	if e.publisher != nil {
 		// Initialize publisher with latest advertisement CID.
 		adCid, err := e.getLatestAdCid(ctx)
@@ -128,6 +198,18 @@ func (e *Engine) Start(ctx context.Context) error {
 				return err
 			}
 		}
+		if handOverAd != cid.Undef {
+			if err = e.queueAnnounce(ctx, handOverAd, 1); err != nil {
+				log.Errorw("Failed to announce hand-over advertisement", "err", err)
+			}
+		}
+
+		if e.announceRetryMaxAge > 0 {
+			var retryCtx context.Context
+			retryCtx, e.retryCancel = context.WithCancel(context.Background())
+			e.retryDone = make(chan struct{})
+			go e.retryAnnounces(retryCtx)
+		}
 	}
 
 	return nil
@@ -151,7 +233,8 @@ func (e *Engine) newPublisher() (legs.Publisher, error) {
 		ds := dsn.Wrap(e.ds, datastore.NewKey("/legs/dtsync/pub"))
 		return dtsync.NewPublisher(e.h, ds, e.lsys, e.pubTopicName, dtOpts...)
 	case HttpPublisher:
-		return httpsync.NewPublisher(e.pubHttpListenAddr, e.lsys, e.h.ID(), e.key)
+		// The head is signed using the host key rather than the signer; see WithSigner.
+		return httpsync.NewPublisher(e.pubHttpListenAddr, e.httpLinkSystem(), e.h.ID(), e.key)
 	default:
 		return nil, fmt.Errorf("unknown publisher kind: %s", e.pubKind)
 	}
@@ -165,24 +248,41 @@ func (e *Engine) newPublisher() (legs.Publisher, error) {
 //
 // See: Engine.Publish.
 func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
-	if err := adv.Validate(); err != nil {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
 		return cid.Undef, err
 	}
-
-	adNode, err := adv.ToNode()
+	c, err := e.publishLocal(ctx, b, adv)
 	if err != nil {
 		return cid.Undef, err
 	}
+	if err = b.Commit(ctx); err != nil {
+		return cid.Undef, fmt.Errorf("failed to commit advertisement: %w", err)
+	}
+	e.adStored(c, adv)
+	return c, nil
+}
 
-	lnk, err := e.lsys.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, adNode)
+// publishLocal writes the given advertisement and the reference to it as the latest advertisement
+// to w. The advertisement is only stored once w is committed, if w is a batch. ErrChainImporting
+// is returned if an advertisement chain is being imported. The chainLk must be held by the caller.
+func (e *Engine) publishLocal(ctx context.Context, w datastore.Write, adv schema.Advertisement) (cid.Cid, error) {
+	if e.importing {
+		return cid.Undef, ErrChainImporting
+	}
+	c, err := e.storeAdv(ctx, w, adv)
 	if err != nil {
-		return cid.Undef, fmt.Errorf("cannot generate advertisement link: %s", err)
+		return cid.Undef, err
 	}
-	c := lnk.(cidlink.Link).Cid
 	log := log.With("adCid", c)
 	log.Info("Stored ad in local link system")
 
-	if err = e.putLatestAdv(ctx, c.Bytes()); err != nil {
+	if err = e.putLatestAdv(ctx, w, c.Bytes()); err != nil {
 		log.Errorw("Failed to update reference to the latest advertisement", "err", err)
 		return cid.Undef, fmt.Errorf("failed to update reference to latest advertisement: %w", err)
 	}
@@ -190,10 +290,52 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 	return c, nil
 }
 
+// storeAdv validates and writes the given advertisement to w without marking it as the latest
+// advertisement.
+func (e *Engine) storeAdv(ctx context.Context, w datastore.Write, adv schema.Advertisement) (cid.Cid, error) {
+	if err := adv.Validate(); err != nil {
+		return cid.Undef, err
+	}
+
+	adNode, err := adv.ToNode()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	lsys := e.lsys
+	lsys.StorageWriteOpener = func(lctx ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		buf := bytes.NewBuffer(nil)
+		return buf, func(lnk ipld.Link) error {
+			c := lnk.(cidlink.Link).Cid
+			return w.Put(lctx.Ctx, datastore.NewKey(c.String()), buf.Bytes())
+		}, nil
+	}
+	lnk, err := lsys.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, adNode)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot generate advertisement link: %s", err)
+	}
+	return lnk.(cidlink.Link).Cid, nil
+}
+
+// adStored records the metrics and emits the event for the given advertisement having been stored.
+func (e *Engine) adStored(c cid.Cid, adv schema.Advertisement) {
+	kind := "put"
+	if adv.IsRm {
+		kind = "remove"
+	}
+	metrics.AdsPublished.WithLabelValues(kind).Inc()
+	p, _ := peer.Decode(adv.Provider)
+	e.emit(AdStoredEvent{AdCid: c, Provider: p, ContextID: adv.ContextID, IsRm: adv.IsRm})
+}
+
 // Publish stores the given advertisement locally via Engine.PublishLocal
 // first, then publishes a message onto the gossipsub to signal the change in
 // the latest advertisement by the provider to indexer nodes.
 //
+// If announce coalescing is enabled, the announcement may be delayed and
+// combined with the announcement of subsequently published advertisements.
+// See: WithAnnounceCoalescing.
+//
 // The publication mechanism uses legs.Publisher internally.
 // See: https://github.com/filecoin-project/go-legs
 func (e *Engine) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
@@ -203,24 +345,96 @@ func (e *Engine) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid
 		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
 	}
 
-	// Only announce the advertisement CID if publisher is configured.
-	if e.publisher != nil {
-		log := log.With("adCid", c)
-		log.Info("Announcing advertisement in pubsub channel")
-		err = e.publisher.UpdateRoot(ctx, c)
-		if err != nil {
-			log.Errorw("Failed to announce advertisement on pubsub channel ", "err", err)
-			return cid.Undef, err
-		}
+	if err = e.queueAnnounce(ctx, c, 1); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
 
-		err = e.httpAnnounce(ctx, c, e.announceURLs)
-		if err != nil {
-			log.Errorw("Failed to announce advertisement via http", "err", err)
-			return cid.Undef, err
-		}
+// queueAnnounce announces the given advertisement CID as the latest, accounting for n newly
+// published advertisements. The announcement is coalesced if configured to do so, and made
+// immediately otherwise.
+func (e *Engine) queueAnnounce(ctx context.Context, c cid.Cid, n int) error {
+	if e.announcer == nil {
+		return e.announce(ctx, c)
 	}
+	return e.announcer.add(ctx, c, n)
+}
 
-	return c, nil
+// PendingAnnounces returns the number of published advertisements that are not yet announced due
+// to announce coalescing. Zero is always returned if coalescing is disabled.
+//
+// See: WithAnnounceCoalescing.
+func (e *Engine) PendingAnnounces() int {
+	if e.announcer == nil {
+		return 0
+	}
+	return e.announcer.pendingLen()
+}
+
+// SyncPolicy returns the policy that determines which peers are allowed to sync advertisements
+// and entries from the engine. Changes made to the returned policy take effect immediately.
+//
+// See: WithSyncPolicy.
+func (e *Engine) SyncPolicy() *policy.Policy {
+	return e.syncPolicy
+}
+
+// announce signals the change in the latest advertisement to indexer nodes over pubsub and
+// direct HTTP announce messages. Announcements are only made if a publisher is configured. Failed
+// direct HTTP announcements are queued for retry; see WithAnnounceRetry.
+func (e *Engine) announce(ctx context.Context, c cid.Cid) error {
+	if e.publisher == nil {
+		return nil
+	}
+
+	log := log.With("adCid", c)
+
+	// Advertisements published concurrently may be announced out of order. Skip announcing an
+	// advertisement that is no longer the latest, so that the root of the publisher never regresses;
+	// the advertisement that superseded it is announced in its place.
+	e.rootLk.Lock()
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		e.rootLk.Unlock()
+		return fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if latest != c {
+		e.rootLk.Unlock()
+		log.Infow("Skipped announcing superseded advertisement", "latest", latest)
+		return nil
+	}
+	log.Info("Announcing advertisement in pubsub channel")
+	err = e.updateRoot(ctx, c)
+	e.rootLk.Unlock()
+	if err != nil {
+		log.Errorw("Failed to announce advertisement on pubsub channel ", "err", err)
+		return err
+	}
+
+	// Failed direct HTTP announcements are queued for retry rather than failing the announcement,
+	// since the advertisement is already stored and announced over pubsub.
+	announceURLs := e.AnnounceURLs()
+	errs, err := e.sendHttpAnnounces(ctx, c, announceURLs)
+	if err != nil {
+		log.Errorw("Failed to announce advertisement via http", "err", err)
+		return err
+	}
+	e.recordHttpAnnounces(ctx, c, announceURLs, errs)
+	return nil
+}
+
+// updateRoot sets the given advertisement CID as the root of the publisher, which announces it
+// over pubsub if supported by the publisher kind.
+func (e *Engine) updateRoot(ctx context.Context, c cid.Cid) error {
+	start := time.Now()
+	err := e.publisher.UpdateRoot(ctx, c)
+	metrics.AnnounceLatency.WithLabelValues("pubsub", "").Observe(metrics.Since(start))
+	if err != nil {
+		metrics.AnnounceFailures.WithLabelValues("pubsub", "").Inc()
+	}
+	e.emit(AdAnnouncedPubsubEvent{AdCid: c, Err: err})
+	return err
 }
 
 func (e *Engine) latestAdToPublish(ctx context.Context) (cid.Cid, error) {
@@ -251,8 +465,7 @@ func (e *Engine) PublishLatest(ctx context.Context) (cid.Cid, error) {
 	}
 	log.Infow("Publishing latest advertisement", "cid", adCid)
 
-	err = e.publisher.UpdateRoot(ctx, adCid)
-	if err != nil {
+	if err = e.updateRoot(ctx, adCid); err != nil {
 		return cid.Undef, err
 	}
 
@@ -276,59 +489,74 @@ func (e *Engine) PublishLatestHTTP(ctx context.Context, announceURLs ...*url.URL
 }
 
 func (e *Engine) httpAnnounce(ctx context.Context, adCid cid.Cid, announceURLs []*url.URL) error {
+	errs, err := e.sendHttpAnnounces(ctx, adCid, announceURLs)
+	if err != nil {
+		return err
+	}
+	var merr error
+	for _, err := range errs {
+		if err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr
+}
+
+// sendHttpAnnounces sends direct HTTP announce messages for the given advertisement to the given
+// indexer URLs concurrently, and returns the outcome of announcing to each URL in the same order.
+// A non-nil error is returned if the announce message could not be constructed.
+func (e *Engine) sendHttpAnnounces(ctx context.Context, adCid cid.Cid, announceURLs []*url.URL) ([]error, error) {
 	if ctx.Err() != nil {
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	ai := &peer.AddrInfo{
 		ID: e.h.ID(),
 	}
 
+	errs := make([]error, len(announceURLs))
 	// The publisher kind determines what addresses to put into the announce
 	// message.
 	switch e.pubKind {
 	case NoPublisher:
 		log.Info("Remote announcements disabled")
-		return nil
+		return errs, nil
 	case DataTransferPublisher:
 		ai.Addrs = e.h.Addrs()
 	case HttpPublisher:
 		maddr, err := hostToMultiaddr(e.pubHttpListenAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		proto, _ := multiaddr.NewMultiaddr("/http")
 		ai.Addrs = append(ai.Addrs, multiaddr.Join(maddr, proto))
 	}
 
-	errChan := make(chan error)
-	for _, u := range announceURLs {
+	var wg sync.WaitGroup
+	for i, u := range announceURLs {
 		// Send HTTP announce to indexers concurrently. If context is canceled,
 		// then Announce requests will be canceled.
-		go func(announceURL *url.URL) {
+		wg.Add(1)
+		go func(i int, announceURL *url.URL) {
+			defer wg.Done()
 			log.Infow("Announcing advertisement over HTTP", "url", announceURL)
+			start := time.Now()
 			cl, err := httpclient.New(announceURL.String())
 			if err != nil {
-				errChan <- fmt.Errorf("failed to create http client for indexer %s: %w", announceURL, err)
-				return
+				err = fmt.Errorf("failed to create http client for indexer %s: %w", announceURL, err)
+			} else if err = cl.Announce(ctx, ai, adCid); err != nil {
+				err = fmt.Errorf("failed to send http announce to indexer %s: %w", announceURL, err)
 			}
-			err = cl.Announce(ctx, ai, adCid)
+			metrics.AnnounceLatency.WithLabelValues("http", announceURL.String()).Observe(metrics.Since(start))
 			if err != nil {
-				errChan <- fmt.Errorf("failed to send http announce to indexer %s: %w", announceURL, err)
-				return
+				metrics.AnnounceFailures.WithLabelValues("http", announceURL.String()).Inc()
 			}
-			errChan <- nil
-		}(u)
-	}
-
-	var errs error
-	for i := 0; i < len(announceURLs); i++ {
-		err := <-errChan
-		if err != nil {
-			errs = multierror.Append(errs, err)
-		}
+			e.emit(AdAnnouncedHTTPEvent{AdCid: adCid, URL: announceURL, Err: err})
+			errs[i] = err
+		}(i, u)
 	}
-	return errs
+	wg.Wait()
+	return errs, nil
 }
 
 // RegisterMultihashLister registers a provider.MultihashLister that is used to
@@ -347,6 +575,12 @@ func (e *Engine) RegisterMultihashLister(mhl provider.MultihashLister) {
 	e.mhLister = mhl
 }
 
+// listMultihashes calls the registered multihash lister, recording its latency.
+func (e *Engine) listMultihashes(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	defer func(start time.Time) { metrics.ListerLatency.Observe(metrics.Since(start)) }(time.Now())
+	return e.mhLister(ctx, p, contextID)
+}
+
 // NotifyPut publishes an advertisement that signals the list of multihashes
 // associated to the given contextID is available by this provider with the
 // given metadata. A provider.MultihashLister is required, and is used to look
@@ -359,34 +593,295 @@ func (e *Engine) RegisterMultihashLister(mhl provider.MultihashLister) {
 func (e *Engine) NotifyPut(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
 	// The multihash lister must have been registered for the linkSystem to
 	// know how to go from contextID to list of CIDs.
-	pID := e.options.provider.ID
-	addrs := e.options.provider.Addrs
-	if provider != nil {
-		pID = provider.ID
-		addrs = provider.Addrs
-	}
+	pID, addrs := e.resolveProvider(provider)
 	return e.publishAdvForIndex(ctx, pID, addrs, contextID, md, false)
 }
 
+// NotifyPutMany publishes one advertisement per given request, in order, the same way as
+// Engine.NotifyPut does. Unlike NotifyPut, the advertisements are only stored locally as they are
+// generated and the resulting head of the advertisement chain is announced once at the end.
+//
+// Errors that occur while generating the advertisement for a request are reported in the
+// corresponding result and do not stop the remaining requests from being processed. The returned
+// error is only non-nil if announcing the resulting head fails, in which case all the
+// advertisements are still stored locally.
+//
+// See: Engine.NotifyPut, Engine.PublishLocal.
+func (e *Engine) NotifyPutMany(ctx context.Context, reqs []provider.NotifyPutRequest) ([]provider.NotifyPutResult, error) {
+	results := make([]provider.NotifyPutResult, len(reqs))
+	head := cid.Undef
+	var count int
+	for i, req := range reqs {
+		if ctx.Err() != nil {
+			results[i].Err = ctx.Err()
+			continue
+		}
+		pID, addrs := e.resolveProvider(req.Provider)
+		c, err := e.publishLocalForIndex(ctx, pID, addrs, req.ContextID, req.Metadata, false, nil)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].AdCid = c
+		head = c
+		count++
+	}
+
+	if head == cid.Undef {
+		log.Info("No new advertisements were generated in batch; skipped announcing.")
+		return results, nil
+	}
+	log.Infow("Generated batch of advertisements; announcing the latest", "count", count, "head", head)
+	return results, e.queueAnnounce(ctx, head, count)
+}
+
+// resolveProvider returns the ID and addresses of the given provider, falling back on the default
+// configured provider if nil.
+func (e *Engine) resolveProvider(provider *peer.AddrInfo) (peer.ID, []multiaddr.Multiaddr) {
+	if provider == nil {
+		e.provLk.RLock()
+		defer e.provLk.RUnlock()
+		return e.options.provider.ID, e.options.provider.Addrs
+	}
+	return provider.ID, provider.Addrs
+}
+
+func (e *Engine) setDefaultProviderAddrs(addrs []multiaddr.Multiaddr) {
+	e.provLk.Lock()
+	defer e.provLk.Unlock()
+	e.options.provider.Addrs = addrs
+}
+
 // NotifyRemove publishes an advertisement that signals the list of multihashes
 // associated to the given contextID is no longer available by this provider.
 //
 // Note that prior to calling this function a provider.MultihashLister must be
 // registered.
 //
+// To remove all context IDs of a provider, see Engine.NotifyRemoveProvider.
+//
 // See: Engine.RegisterMultihashLister, Engine.Publish.
 func (e *Engine) NotifyRemove(ctx context.Context, provider peer.ID, contextID []byte) (cid.Cid, error) {
-	// TODO: add support for "delete all" for provider
 	if provider == "" {
 		provider = e.options.provider.ID
 	}
 	return e.publishAdvForIndex(ctx, provider, nil, contextID, metadata.Metadata{}, true)
 }
 
+// NotifyRemoveProvider publishes one removal advertisement per context ID that is currently
+// advertised for the given provider, and removes all the local mappings associated to them,
+// including the addresses last advertised for the provider. This is useful for retiring a provider
+// identity entirely, e.g. one that was previously passed to Engine.NotifyPut as an extra provider.
+//
+// The removal advertisements are all stored locally first and only the resulting head of the
+// advertisement chain is announced, once. The CIDs of the generated removal advertisements are
+// returned in the order they were appended to the chain.
+//
+// If providerID is empty then the default configured provider is assumed.
+// provider.ErrContextIDNotFound is returned if no context IDs are advertised for the provider.
+//
+// See: Engine.NotifyRemove.
+func (e *Engine) NotifyRemoveProvider(ctx context.Context, providerID peer.ID) ([]cid.Cid, error) {
+	if providerID == "" {
+		providerID = e.options.provider.ID
+	}
+	log := log.With("providerID", providerID)
+
+	// Collect the context IDs first, since removing them mutates the mappings being walked.
+	var contextIDs [][]byte
+	err := e.forEachKeyCidMapping(ctx, func(p peer.ID, contextID []byte, _ cid.Cid) error {
+		if p == providerID {
+			contextIDs = append(contextIDs, contextID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list context IDs of provider: %w", err)
+	}
+	if len(contextIDs) == 0 {
+		return nil, provider.ErrContextIDNotFound
+	}
+	log.Infow("Removing all context IDs of provider", "count", len(contextIDs))
+
+	adCids := make([]cid.Cid, 0, len(contextIDs))
+	var errs error
+	// Drop the addresses of the provider along with its last context ID, so that neither outlives
+	// the other.
+	deleteAddrs := func(ctx context.Context, w datastore.Write) error {
+		return w.Delete(ctx, e.providerAddrsKey(providerID))
+	}
+	for i, contextID := range contextIDs {
+		var extra func(context.Context, datastore.Write) error
+		if i == len(contextIDs)-1 {
+			extra = deleteAddrs
+		}
+		c, err := e.publishLocalForIndex(ctx, providerID, nil, contextID, metadata.Metadata{}, true, extra)
+		if err == nil {
+			adCids = append(adCids, c)
+			continue
+		}
+		errs = fmt.Errorf("failed to remove context ID %s: %w", base64.StdEncoding.EncodeToString(contextID), err)
+		break
+	}
+
+	if len(adCids) != 0 {
+		if err := e.queueAnnounce(ctx, adCids[len(adCids)-1], len(adCids)); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return adCids, errs
+}
+
+// UpdateProviderAddrs publishes an advertisement that signals the change of retrieval addresses
+// of the given provider to indexer nodes, without re-advertising any of its content. The
+// advertisement has no entries, and carries the metadata of the latest advertisement.
+//
+// If the provider ID is empty then the default configured provider is assumed. If the provider is
+// the default configured provider, then subsequent advertisements generated via Engine.NotifyPut
+// with nil provider will also use the given addresses.
+//
+// provider.ErrAlreadyAdvertised is returned if the given addresses are the same as the ones last
+// advertised for the provider.
+//
+// This function returns the ID of the advertisement published.
+func (e *Engine) UpdateProviderAddrs(ctx context.Context, ai peer.AddrInfo) (cid.Cid, error) {
+	if ai.ID == "" {
+		ai.ID = e.provider.ID
+	}
+	log := log.With("providerID", ai.ID)
+
+	stringAddrs := make([]string, 0, len(ai.Addrs))
+	for _, addr := range ai.Addrs {
+		stringAddrs = append(stringAddrs, addr.String())
+	}
+
+	prevAddrs, err := e.lastAdvertisedAddrs(ctx, ai.ID)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if prevAddrs != nil && equalStrings(prevAddrs, stringAddrs) {
+		return cid.Undef, provider.ErrAlreadyAdvertised
+	}
+	log.Infow("Creating provider addresses update advertisement", "addrs", stringAddrs, "prevAddrs", prevAddrs)
+
+	// Reuse the latest metadata, since the advertisement still requires a valid one.
+	_, latestAd, err := e.GetLatestAdv(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	var mdBytes []byte
+	if latestAd != nil {
+		mdBytes = latestAd.Metadata
+	} else {
+		// The advertisement requires a valid metadata. Use a valid empty metadata.
+		md := metadata.New(metadata.Bitswap{})
+		mdBytes, err = md.MarshalBinary()
+		if err != nil {
+			return cid.Undef, err
+		}
+	}
+
+	adv := schema.Advertisement{
+		Provider:  ai.ID.String(),
+		Addresses: stringAddrs,
+		Entries:   schema.NoEntries,
+		Metadata:  mdBytes,
+	}
+	c, err := e.publishAddrsLocal(ctx, ai.ID, adv)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if ai.ID == e.provider.ID {
+		e.setDefaultProviderAddrs(ai.Addrs)
+	}
+	if err = e.queueAnnounce(ctx, c, 1); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
+
+// publishAddrsLocal signs and stores locally the given provider addresses update advertisement,
+// along with the update of the provider to addresses mapping, in a single datastore batch.
+func (e *Engine) publishAddrsLocal(ctx context.Context, p peer.ID, adv schema.Advertisement) (cid.Cid, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+
+	if err := e.linkAndSign(ctx, &adv); err != nil {
+		return cid.Undef, err
+	}
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := e.putProviderAddrsMap(ctx, b, p, adv.Addresses); err != nil {
+		return cid.Undef, fmt.Errorf("failed to write provider to addresses mapping: %s", err)
+	}
+	c, err := e.publishLocal(ctx, b, adv)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	if err = b.Commit(ctx); err != nil {
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	e.adStored(c, adv)
+	return c, nil
+}
+
+// lastAdvertisedAddrs returns the addresses last advertised for the given provider, or nil if
+// unknown. For datastores that predate the provider addresses mapping, the latest advertisement is
+// used if it belongs to the given provider.
+func (e *Engine) lastAdvertisedAddrs(ctx context.Context, p peer.ID) ([]string, error) {
+	addrs, err := e.getProviderAddrsMap(ctx, p)
+	if err == nil {
+		return addrs, nil
+	}
+	if err != datastore.ErrNotFound {
+		return nil, fmt.Errorf("could not get addresses for provider: %w", err)
+	}
+
+	_, latestAd, err := e.GetLatestAdv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latestAd == nil || latestAd.IsRm || latestAd.Provider != p.String() {
+		return nil, nil
+	}
+	if latestAd.Addresses == nil {
+		return []string{}, nil
+	}
+	return latestAd.Addresses, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // Shutdown shuts down the engine and discards all resources opened by the
 // engine. The engine is no longer usable after the call to this function.
 func (e *Engine) Shutdown() error {
 	var errs error
+	if e.announcer != nil {
+		if err := e.announcer.close(context.TODO()); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("error announcing pending advertisements: %s", err))
+		}
+	}
+	if e.retryCancel != nil {
+		e.retryCancel()
+		<-e.retryDone
+	}
+	if e.dtUnsub != nil {
+		e.dtUnsub()
+	}
 	if e.publisher != nil {
 		if err := e.publisher.Close(); err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("error closing leg publisher: %s", err))
@@ -395,6 +890,7 @@ func (e *Engine) Shutdown() error {
 	if err := e.entriesChunker.Close(); err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("error closing link entriesChunker: %s", err))
 	}
+	e.closeSubscriptions()
 	return errs
 }
 
@@ -407,7 +903,7 @@ func (e *Engine) GetAdv(_ context.Context, adCid cid.Cid) (*schema.Advertisement
 	lsys := e.vanillaLinkSystem()
 	n, err := lsys.Load(ipld.LinkContext{}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
 	if err != nil {
-		return nil, fmt.Errorf("cannot load advertisement from blockstore with vanilla linksystem: %s", err)
+		return nil, fmt.Errorf("cannot load advertisement from blockstore with vanilla linksystem: %w", err)
 	}
 	return schema.UnwrapAdvertisement(n)
 }
@@ -433,6 +929,71 @@ func (e *Engine) GetLatestAdv(ctx context.Context) (cid.Cid, *schema.Advertiseme
 }
 
 func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, isRm bool) (cid.Cid, error) {
+	c, err := e.publishLocalForIndex(ctx, p, addrs, contextID, md, isRm, nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err = e.queueAnnounce(ctx, c, 1); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
+
+// publishLocalForIndex generates the advertisement for the given provider and contextID, and
+// stores it locally as the latest advertisement without announcing it.
+//
+// The updates to the internal mappings, the advertisement and the reference to the latest
+// advertisement are all written in a single datastore batch, so that a crash cannot leave the
+// mappings referring to an advertisement that was never stored, or vice versa. Batches are atomic
+// for datastores that support it, e.g. LevelDB and Badger; any half-applied state otherwise is
+// reported at startup, and repaired if enabled. See: WithConsistencyCheck, WithConsistencyRepair.
+//
+// If extra is not nil, it is called to write any further updates to the same batch.
+func (e *Engine) publishLocalForIndex(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, isRm bool, extra func(context.Context, datastore.Write) error) (cid.Cid, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+	// Hold the context ID lock throughout, so that concurrent publications for the same context ID
+	// read the mappings written by one another.
+	defer e.contextLks.lock(p, contextID)()
+
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	adv, err := e.generateAdvForIndex(ctx, b, p, addrs, contextID, md, isRm)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if extra != nil {
+		if err = extra(ctx, b); err != nil {
+			return cid.Undef, err
+		}
+	}
+
+	// Only hold the chain lock once the advertisement is generated, since generating it may
+	// involve listing and chunking multihashes.
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+	if err = e.linkAndSign(ctx, adv); err != nil {
+		return cid.Undef, err
+	}
+	c, err := e.publishLocal(ctx, b, *adv)
+	if err != nil {
+		log.Errorw("Failed to store advertisement locally", "err", err)
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	if err = b.Commit(ctx); err != nil {
+		log.Errorw("Failed to commit advertisement and mappings", "err", err)
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	e.adStored(c, *adv)
+	return c, nil
+}
+
+// generateAdvForIndex writes the updates to the internal mappings for the given provider and
+// contextID to w, and generates the corresponding advertisement. The advertisement is neither
+// linked to the chain nor signed; see Engine.publishLocalForIndex.
+func (e *Engine) generateAdvForIndex(ctx context.Context, w datastore.Write, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, isRm bool) (*schema.Advertisement, error) {
 	var err error
 	var cidsLnk cidlink.Link
 
@@ -441,7 +1002,7 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 	c, err := e.getKeyCidMap(ctx, p, contextID)
 	if err != nil {
 		if err != datastore.ErrNotFound {
-			return cid.Undef, fmt.Errorf("cound not not get entries cid by provider + context id: %s", err)
+			return nil, fmt.Errorf("cound not not get entries cid by provider + context id: %s", err)
 		}
 	}
 
@@ -456,34 +1017,35 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			log.Info("Generating entries linked list for advertisement")
 			// If no lister registered return error.
 			if e.mhLister == nil {
-				return cid.Undef, provider.ErrNoMultihashLister
+				return nil, provider.ErrNoMultihashLister
 			}
 
 			// Call the lister.
-			mhIter, err := e.mhLister(ctx, p, contextID)
+			mhIter, err := e.listMultihashes(ctx, p, contextID)
 			if err != nil {
-				return cid.Undef, err
+				return nil, err
 			}
 			// Generate the linked list ipld.Link that is added to the
 			// advertisement and used for ingestion.
 			lnk, err := e.entriesChunker.Chunk(ctx, mhIter)
 			if err != nil {
-				return cid.Undef, fmt.Errorf("could not generate entries list: %s", err)
+				return nil, fmt.Errorf("could not generate entries list: %s", err)
 			}
 			cidsLnk = lnk.(cidlink.Link)
+			e.emit(EntriesChunkedEvent{Root: cidsLnk.Cid, Provider: p, ContextID: contextID})
 
 			// Store the relationship between providerID, contextID and CID of the
 			// advertised list of Cids.
-			err = e.putKeyCidMap(ctx, p, contextID, cidsLnk.Cid)
+			err = e.putKeyCidMap(ctx, w, p, contextID, cidsLnk.Cid)
 			if err != nil {
-				return cid.Undef, fmt.Errorf("failed to write provider + context id to entries cid mapping: %s", err)
+				return nil, fmt.Errorf("failed to write provider + context id to entries cid mapping: %s", err)
 			}
 		} else {
 			// Lookup metadata for this providerID and contextID.
 			prevMetadata, err := e.getKeyMetadataMap(ctx, p, contextID)
 			if err != nil {
 				if err != datastore.ErrNotFound {
-					return cid.Undef, fmt.Errorf("could not get metadata for provider + context id: %s", err)
+					return nil, fmt.Errorf("could not get metadata for provider + context id: %s", err)
 				}
 				log.Warn("No metadata for existing provider + context ID, generating new advertisement")
 			}
@@ -491,7 +1053,7 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			if md.Equal(prevMetadata) {
 				// Metadata is the same; no change, no need for new
 				// advertisement.
-				return cid.Undef, provider.ErrAlreadyAdvertised
+				return nil, provider.ErrAlreadyAdvertised
 			}
 
 			// Linked list is the same, but metadata is different, so generate
@@ -499,29 +1061,29 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			cidsLnk = cidlink.Link{Cid: c}
 		}
 
-		if err = e.putKeyMetadataMap(ctx, p, contextID, &md); err != nil {
-			return cid.Undef, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
+		if err = e.putKeyMetadataMap(ctx, w, p, contextID, &md); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
 		}
 	} else {
 		log.Info("Creating removal advertisement")
 
 		if c == cid.Undef {
-			return cid.Undef, provider.ErrContextIDNotFound
+			return nil, provider.ErrContextIDNotFound
 		}
 
 		// If removing by context ID, it means the list of CIDs is not needed
 		// anymore, so we can remove the entry from the datastore.
-		err = e.deleteKeyCidMap(ctx, p, contextID)
+		err = e.deleteKeyCidMap(ctx, w, p, contextID)
 		if err != nil {
-			return cid.Undef, fmt.Errorf("failed to delete provider + context id to entries cid mapping: %s", err)
+			return nil, fmt.Errorf("failed to delete provider + context id to entries cid mapping: %s", err)
 		}
-		err = e.deleteCidKeyMap(ctx, c)
+		err = e.deleteCidKeyMap(ctx, w, c)
 		if err != nil {
-			return cid.Undef, fmt.Errorf("failed to delete entries cid to provider + context id mapping: %s", err)
+			return nil, fmt.Errorf("failed to delete entries cid to provider + context id mapping: %s", err)
 		}
-		err = e.deleteKeyMetadataMap(ctx, p, contextID)
+		err = e.deleteKeyMetadataMap(ctx, w, p, contextID)
 		if err != nil {
-			return cid.Undef, fmt.Errorf("failed to delete provider + context id to metadata mapping: %s", err)
+			return nil, fmt.Errorf("failed to delete provider + context id to metadata mapping: %s", err)
 		}
 
 		// Create an advertisement to delete content by contextID by specifying
@@ -535,7 +1097,7 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 
 	mdBytes, err := md.MarshalBinary()
 	if err != nil {
-		return cid.Undef, err
+		return nil, err
 	}
 
 	var stringAddrs []string
@@ -552,25 +1114,36 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 		IsRm:      isRm,
 	}
 
+	if !isRm {
+		if err := e.putProviderAddrsMap(ctx, w, p, stringAddrs); err != nil {
+			return nil, fmt.Errorf("failed to write provider to addresses mapping: %s", err)
+		}
+	}
+
+	return &adv, nil
+}
+
+// linkAndSign links the given advertisement to the current latest advertisement, if any, and signs
+// it using the engine's signer. The chain lock must be held until the advertisement is marked as the
+// latest.
+func (e *Engine) linkAndSign(ctx context.Context, adv *schema.Advertisement) error {
 	// Get the previous advertisement that was generated.
 	prevAdvID, err := e.getLatestAdCid(ctx)
 	if err != nil {
-		return cid.Undef, fmt.Errorf("could not get latest advertisement: %s", err)
+		return fmt.Errorf("could not get latest advertisement: %s", err)
 	}
 
 	// Check for cid.Undef for the previous link. If this is the case, then
 	// this means there is a "cid too short" error in IPLD links serialization.
 	if prevAdvID != cid.Undef {
-		log.Info("Latest advertisement CID was undefined - no previous advertisement")
 		prev := ipld.Link(cidlink.Link{Cid: prevAdvID})
 		adv.PreviousID = prev
+	} else {
+		log.Info("Latest advertisement CID was undefined - no previous advertisement")
 	}
 
 	// Sign the advertisement.
-	if err := adv.Sign(e.key); err != nil {
-		return cid.Undef, err
-	}
-	return e.Publish(ctx, adv)
+	return e.signer.Sign(ctx, adv)
 }
 
 func (e *Engine) keyToCidKey(provider peer.ID, contextID []byte) datastore.Key {
@@ -582,6 +1155,74 @@ func (e *Engine) keyToCidKey(provider peer.ID, contextID []byte) datastore.Key {
 	}
 }
 
+// forEachKeyCidMapping calls fn for every provider and context ID that is mapped to an entries CID
+// in the datastore. Iteration stops at the first error returned by fn.
+func (e *Engine) forEachKeyCidMapping(ctx context.Context, fn func(peer.ID, []byte, cid.Cid) error) error {
+	results, err := e.ds.Query(ctx, query.Query{Prefix: keyToCidMapPrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if r.Error != nil {
+			return fmt.Errorf("cannot read provider + context id to entries cid mapping: %w", r.Error)
+		}
+		_, c, err := cid.CidFromBytes(r.Value)
+		if err != nil {
+			return err
+		}
+		k := datastore.RawKey(r.Key)
+		p, contextID, err := e.providerAndContextFromKeyCidKey(ctx, k, c)
+		if err != nil {
+			return err
+		}
+		if err := fn(p, contextID, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// providerAndContextFromKeyCidKey recovers the provider and context ID from which the given
+// datastore key was generated via Engine.keyToCidKey.
+//
+// The reverse mapping from entries CID to provider and context ID is checked first, since it
+// records both values unambiguously. It only stores a single mapping per entries CID though. When
+// it does not correspond to the given key, the values are parsed from the key itself.
+func (e *Engine) providerAndContextFromKeyCidKey(ctx context.Context, k datastore.Key, c cid.Cid) (peer.ID, []byte, error) {
+	pAndC, err := e.getCidKeyMap(ctx, c)
+	switch {
+	case err == nil:
+		p := e.provider.ID
+		if len(pAndC.Provider) != 0 {
+			p, err = peer.IDFromBytes(pAndC.Provider)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		if e.keyToCidKey(p, pAndC.ContextID) == k {
+			return p, pAndC.ContextID, nil
+		}
+	case err != datastore.ErrNotFound:
+		return "", nil, err
+	}
+
+	rest := strings.TrimPrefix(k.String(), "/"+keyToCidMapPrefix)
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		if p, err := peer.Decode(rest[i+1:]); err == nil {
+			contextID := []byte(rest[:i])
+			if e.keyToCidKey(p, contextID) == k {
+				return p, contextID, nil
+			}
+		}
+	}
+	return e.provider.ID, []byte(rest), nil
+}
+
 func (e *Engine) cidToKeyKey(c cid.Cid) datastore.Key {
 	return datastore.NewKey(cidToKeyMapPrefix + c.String())
 }
@@ -599,11 +1240,11 @@ func (e *Engine) keyToMetadataKey(provider peer.ID, contextID []byte) datastore.
 	}
 }
 
-func (e *Engine) putKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte, c cid.Cid) error {
+func (e *Engine) putKeyCidMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, c cid.Cid) error {
 	// Store the map Key-Cid to know what CidLink to put in advertisement when
 	// notifying about a removal.
 
-	err := e.ds.Put(ctx, e.keyToCidKey(provider, contextID), c.Bytes())
+	err := w.Put(ctx, e.keyToCidKey(provider, contextID), c.Bytes())
 	if err != nil {
 		return err
 	}
@@ -619,7 +1260,7 @@ func (e *Engine) putKeyCidMap(ctx context.Context, provider peer.ID, contextID [
 	if err != nil {
 		return err
 	}
-	return e.ds.Put(ctx, e.cidToProviderAndKeyKey(c), m)
+	return w.Put(ctx, e.cidToProviderAndKeyKey(c), m)
 }
 
 func (e *Engine) getKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte) (cid.Cid, error) {
@@ -631,16 +1272,16 @@ func (e *Engine) getKeyCidMap(ctx context.Context, provider peer.ID, contextID [
 	return d, err
 }
 
-func (e *Engine) deleteKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte) error {
-	return e.ds.Delete(ctx, e.keyToCidKey(provider, contextID))
+func (e *Engine) deleteKeyCidMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToCidKey(provider, contextID))
 }
 
-func (e *Engine) deleteCidKeyMap(ctx context.Context, c cid.Cid) error {
-	err := e.ds.Delete(ctx, e.cidToProviderAndKeyKey(c))
+func (e *Engine) deleteCidKeyMap(ctx context.Context, w datastore.Write, c cid.Cid) error {
+	err := w.Delete(ctx, e.cidToProviderAndKeyKey(c))
 	if err != nil {
 		return err
 	}
-	return e.ds.Delete(ctx, e.cidToKeyKey(c))
+	return w.Delete(ctx, e.cidToKeyKey(c))
 }
 
 type providerAndContext struct {
@@ -671,12 +1312,12 @@ func (e *Engine) getCidKeyMap(ctx context.Context, c cid.Cid) (*providerAndConte
 	return &pAndC, nil
 }
 
-func (e *Engine) putKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte, metadata *metadata.Metadata) error {
+func (e *Engine) putKeyMetadataMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, metadata *metadata.Metadata) error {
 	data, err := metadata.MarshalBinary()
 	if err != nil {
 		return err
 	}
-	return e.ds.Put(ctx, e.keyToMetadataKey(provider, contextID), data)
+	return w.Put(ctx, e.keyToMetadataKey(provider, contextID), data)
 }
 
 func (e *Engine) getKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte) (metadata.Metadata, error) {
@@ -691,12 +1332,36 @@ func (e *Engine) getKeyMetadataMap(ctx context.Context, provider peer.ID, contex
 	return md, nil
 }
 
-func (e *Engine) deleteKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte) error {
-	return e.ds.Delete(ctx, e.keyToMetadataKey(provider, contextID))
+func (e *Engine) deleteKeyMetadataMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToMetadataKey(provider, contextID))
+}
+
+func (e *Engine) providerAddrsKey(provider peer.ID) datastore.Key {
+	return datastore.NewKey(providerToAddrsMapPrefix + provider.String())
+}
+
+func (e *Engine) putProviderAddrsMap(ctx context.Context, w datastore.Write, provider peer.ID, addrs []string) error {
+	data, err := json.Marshal(addrs)
+	if err != nil {
+		return err
+	}
+	return w.Put(ctx, e.providerAddrsKey(provider), data)
+}
+
+func (e *Engine) getProviderAddrsMap(ctx context.Context, provider peer.ID) ([]string, error) {
+	data, err := e.ds.Get(ctx, e.providerAddrsKey(provider))
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
 }
 
-func (e *Engine) putLatestAdv(ctx context.Context, advID []byte) error {
-	return e.ds.Put(ctx, dsLatestAdvKey, advID)
+func (e *Engine) putLatestAdv(ctx context.Context, w datastore.Write, advID []byte) error {
+	return w.Put(ctx, dsLatestAdvKey, advID)
 }
 
 func (e *Engine) getLatestAdCid(ctx context.Context) (cid.Cid, error) {
@@ -47,6 +47,14 @@ func (e *Engine) Datastore() datastore.Datastore {
 	return e.ds
 }
 
+// SetImportBatchSize sets the number of advertisements imported per batch until the test ends,
+// exposed for testing purposes only.
+func SetImportBatchSize(t *testing.T, n int) {
+	prev := importBatchSize
+	importBatchSize = n
+	t.Cleanup(func() { importBatchSize = prev })
+}
+
 func Test_EmptyConfigSetsDefaults(t *testing.T) {
 	engine, err := New()
 	require.NoError(t, err)
@@ -4,11 +4,18 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,13 +23,17 @@ import (
 	"github.com/filecoin-project/go-legs/p2p/protocol/head"
 	provider "github.com/filecoin-project/index-provider"
 	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/engine/policy"
 	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/metrics"
 	"github.com/filecoin-project/index-provider/testutil"
 	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	dssync "github.com/ipfs/go-datastore/sync"
 	leveldb "github.com/ipfs/go-ds-leveldb"
+	carblockstore "github.com/ipld/go-car/v2/blockstore"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
@@ -30,10 +41,14 @@ import (
 	"github.com/ipld/go-ipld-prime/traversal/selector"
 	selectorbuilder "github.com/ipld/go-ipld-prime/traversal/selector/builder"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
 )
@@ -469,6 +484,147 @@ func TestEngine_ProducesSingleChainForMultipleProviders(t *testing.T) {
 	require.Equal(t, ad.PreviousID.(cidlink.Link).Cid, gotPutAdCid1)
 }
 
+func TestEngine_NotifyPutConcurrentlyProducesSingleChain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	const count = 50
+	mhs := make(map[string][]multihash.Multihash, count)
+	for i := 0; i < count; i++ {
+		mhs[fmt.Sprintf("fish-%d", i)] = testutil.RandomMultihashes(t, rng, 10)
+	}
+
+	// Yield on every datastore read, so that concurrent publishes interleave even on a single CPU.
+	ds := &yieldingDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	})
+
+	// Publish all advertisements at once, so that they race to be appended to the chain.
+	adCids := make(chan cid.Cid, count)
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	for contextID := range mhs {
+		wg.Add(1)
+		go func(contextID string) {
+			defer wg.Done()
+			adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), metadata.New(metadata.Bitswap{}))
+			if err != nil {
+				errs <- err
+				return
+			}
+			adCids <- adCid
+		}(contextID)
+	}
+	wg.Wait()
+	close(adCids)
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	published := make(map[cid.Cid]struct{}, count)
+	for adCid := range adCids {
+		published[adCid] = struct{}{}
+	}
+	require.Len(t, published, count)
+
+	// Every published advertisement must be reachable from the latest one.
+	next, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	var chained int
+	for next != cid.Undef {
+		require.Contains(t, published, next)
+		delete(published, next)
+		chained++
+		ad, err := subject.GetAdv(ctx, next)
+		require.NoError(t, err)
+		next = cid.Undef
+		if ad.PreviousID != nil {
+			next = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	require.Equal(t, count, chained)
+	require.Empty(t, published)
+}
+
+func TestEngine_NotifyPutAndRemoveSameContextIDConcurrently(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	mhs := testutil.RandomMultihashes(t, rng, 10)
+	contextID := []byte("fish")
+
+	ds := &yieldingDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	const count = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = subject.NotifyPut(ctx, nil, contextID, metadata.New(metadata.Bitswap{}))
+			} else {
+				_, err = subject.NotifyRemove(ctx, "", contextID)
+			}
+			if err != nil && err != provider.ErrAlreadyAdvertised && err != provider.ErrContextIDNotFound {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// The chain must alternate between puts and removes of the context ID, starting with a put,
+	// and its latest advertisement must agree with the mappings.
+	var ads []*schema.Advertisement
+	next, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for next != cid.Undef {
+		ad, err := subject.GetAdv(ctx, next)
+		require.NoError(t, err)
+		ads = append([]*schema.Advertisement{ad}, ads...)
+		next = cid.Undef
+		if ad.PreviousID != nil {
+			next = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	require.NotEmpty(t, ads)
+	for i, ad := range ads {
+		require.Equal(t, i%2 == 1, ad.IsRm, "advertisement %d", i)
+	}
+	state, err := subject.GetContextState(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, ads[len(ads)-1].IsRm, state.IsRm)
+	require.Equal(t, state.IsRm, state.EntriesCid == cid.Undef)
+}
+
+// yieldingDatastore yields the processor to other goroutines after every read.
+type yieldingDatastore struct {
+	datastore.Batching
+}
+
+func (y *yieldingDatastore) Get(ctx context.Context, key datastore.Key) ([]byte, error) {
+	defer runtime.Gosched()
+	return y.Batching.Get(ctx, key)
+}
+
 func TestEngine_NotifyPutUseDefaultProviderAndAddressesWhenNoneGiven(t *testing.T) {
 	ctx := contextWithTimeout(t)
 	rng := rand.New(rand.NewSource(1413))
@@ -573,148 +729,1513 @@ func TestEngine_ShouldHaveSameChunksInChunkerForSameCIDs(t *testing.T) {
 	require.Equal(t, ad1.Entries, ad2.Entries)
 }
 
-func createAd(t *testing.T, contextID []byte, provider string, addrs []string, entries string, isRm bool, prevId string) *schema.Advertisement {
-	var prevLink ipld.Link
-	if prevId != "" {
-		p, err := cid.Parse(prevId)
+func TestEngine_NotifyPutManyChainsAdsAndReportsPerItemErrors(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	mhs1 := testutil.RandomMultihashes(t, rng, 42)
+	mhs2 := testutil.RandomMultihashes(t, rng, 42)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	contextID1 := []byte("fish")
+	contextID2 := []byte("bird")
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		switch string(contextID) {
+		case string(contextID1):
+			return provider.SliceMultihashIterator(mhs1), nil
+		case string(contextID2):
+			return provider.SliceMultihashIterator(mhs2), nil
+		}
+		return nil, errors.New("not found")
+	})
+
+	prevAdCid, err := subject.NotifyPut(ctx, nil, contextID1, metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	otherProvider := testutil.NewID(t)
+	results, err := subject.NotifyPutMany(ctx, []provider.NotifyPutRequest{
+		{ContextID: contextID1, Metadata: metadata.New(metadata.Bitswap{})},
+		{ContextID: contextID2, Metadata: metadata.New(metadata.Bitswap{})},
+		{ContextID: []byte("unknown"), Metadata: metadata.New(metadata.Bitswap{})},
+		{Provider: &peer.AddrInfo{ID: otherProvider}, ContextID: contextID1, Metadata: metadata.New(metadata.Bitswap{})},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.Equal(t, provider.ErrAlreadyAdvertised, results[0].Err)
+	require.Equal(t, cid.Undef, results[0].AdCid)
+	require.NoError(t, results[1].Err)
+	require.NotEqual(t, cid.Undef, results[1].AdCid)
+	require.Error(t, results[2].Err)
+	require.Equal(t, cid.Undef, results[2].AdCid)
+	require.NoError(t, results[3].Err)
+	require.NotEqual(t, cid.Undef, results[3].AdCid)
+
+	// Assert the successfully generated ads are chained in order of requests.
+	gotLatestAdCid, gotLatestAd, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, results[3].AdCid, gotLatestAdCid)
+	require.Equal(t, otherProvider.String(), gotLatestAd.Provider)
+	require.Equal(t, results[1].AdCid, gotLatestAd.PreviousID.(cidlink.Link).Cid)
+
+	ad, err := subject.GetAdv(ctx, results[1].AdCid)
+	require.NoError(t, err)
+	require.Equal(t, contextID2, ad.ContextID)
+	require.Equal(t, prevAdCid, ad.PreviousID.(cidlink.Link).Cid)
+}
+
+func TestEngine_AnnounceCoalescing(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	ts, announced := newAnnounceServer(t, nil)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithPublisherKind(engine.DataTransferPublisher),
+		engine.WithTopicName(t.Name()),
+		engine.WithDirectAnnounce(ts.URL),
+		engine.WithAnnounceCoalescing(time.Hour, 3),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+
+	notifyPut := func(contextID string) cid.Cid {
+		c, err := subject.NotifyPut(ctx, nil, []byte(contextID), metadata.New(metadata.Bitswap{}))
 		require.NoError(t, err)
-		prevLink = cidlink.Link{Cid: p}
+		return c
 	}
-	entriesCID, err := cid.Parse(entries)
-	require.NoError(t, err)
-	return &schema.Advertisement{ContextID: contextID, Provider: provider, Addresses: addrs, IsRm: isRm, PreviousID: prevLink, Entries: cidlink.Link{Cid: entriesCID}}
+
+	notifyPut("fish")
+	notifyPut("bird")
+	require.Equal(t, 2, subject.PendingAnnounces())
+	require.Len(t, announced, 0)
+
+	// Reaching the count announces the latest head only.
+	wantHead := notifyPut("lobster")
+	require.Equal(t, 0, subject.PendingAnnounces())
+	require.Equal(t, wantHead, (<-announced).Cid)
+	require.Len(t, announced, 0)
+
+	// Shutdown announces anything pending.
+	wantHead = notifyPut("barreleye")
+	require.Equal(t, 1, subject.PendingAnnounces())
+	require.NoError(t, subject.Shutdown())
+	require.Equal(t, 0, subject.PendingAnnounces())
+	require.Equal(t, wantHead, (<-announced).Cid)
 }
 
-func TestEngine_DatastoreBackwardsCompatibilityTest(t *testing.T) {
-	tempDir := t.TempDir()
+func TestEngine_AnnounceCoalescingWindow(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
 
-	// copying testdata into the test dir
-	testutil.CopyDir(t, filepath.Join(testutil.ThisDir(t), "../testdata/datastore.ds"), tempDir)
+	ts, announced := newAnnounceServer(t, nil)
 
-	ds, _ := leveldb.NewDatastore(tempDir, nil)
-	defer ds.Close()
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithPublisherKind(engine.DataTransferPublisher),
+		engine.WithTopicName(t.Name()),
+		engine.WithDirectAnnounce(ts.URL),
+		engine.WithAnnounceCoalescing(100*time.Millisecond, 0),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
 
-	// setting up engine with the configuration that was used to generate the datastore
-	ma1, _ := multiaddr.NewMultiaddr("/ip6/::1/tcp/62698")
-	ma2, _ := multiaddr.NewMultiaddr("/ip4/192.168.1.161/tcp/62695")
-	ma3, _ := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/62695")
-	pID, _ := peer.IDFromString("QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES")
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	wantHead, err := subject.NotifyPut(ctx, nil, []byte("bird"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	select {
+	case got := <-announced:
+		require.Equal(t, wantHead, got.Cid)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for coalesced announcement")
+	}
+	require.Equal(t, 0, subject.PendingAnnounces())
+	require.Len(t, announced, 0)
+}
 
+func TestEngine_NotifyRemoveProvider(t *testing.T) {
 	ctx := contextWithTimeout(t)
-	subject, err := engine.New(engine.WithDatastore(ds), engine.WithProvider(peer.AddrInfo{ID: pID, Addrs: []multiaddr.Multiaddr{ma1, ma2, ma3}}))
+	rng := rand.New(rand.NewSource(1413))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds))
 	require.NoError(t, err)
 	err = subject.Start(ctx)
 	require.NoError(t, err)
 	defer subject.Shutdown()
 
-	// walking back the ad chain
-	existingRoot, _ := cid.Parse("baguqeeraix5q35zho3z2x5hqsa2iga3372qj4txsr4ooc2zvbyownka57gzq")
-	ad, err := subject.GetAdv(ctx, existingRoot)
-	require.NoError(t, err)
+	mhs := make(map[string][]multihash.Multihash)
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if _, ok := mhs[string(contextID)]; !ok {
+			mhs[string(contextID)] = testutil.RandomMultihashes(t, rng, 42)
+		}
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	})
 
-	verifyAd(t, ctx, subject, createAd(t, []byte("byte"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "bafkreehdwdcefgh4dqkjv67uzcmw7oje", true, "baguqeerahjpn2qtt3qwbzcwbytruxqtqjoitv7zv4d7dksppw7swl4rt3dqq"), ad)
+	retiredID := testutil.NewID(t)
+	retiredAddrs, _ := multiaddr.NewMultiaddr("/ip4/0.0.0.0/tcp/1234/http")
+	retired := &peer.AddrInfo{ID: retiredID, Addrs: []multiaddr.Multiaddr{retiredAddrs}}
+	md := metadata.New(metadata.Bitswap{})
 
-	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	// Advertise overlapping context IDs for both the default and the retired provider.
+	_, err = subject.NotifyPut(ctx, retired, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, retired, []byte("sea/lion"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("bird"), md)
 	require.NoError(t, err)
-	verifyAd(t, ctx, subject, createAd(t, []byte("tree"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeerapsyb2pobz7vmcc2i4f5pe7chjs7bhgmfueqiuqwzsscmliwop45q", false, "baguqeerattk5otsdvvlpnno3a5x5ruowvjt7iqxtwqrwv5jhqbkq62mloc7a"), ad)
 
-	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	retiredAddrsKey := datastore.NewKey("map/provAddrs/" + retiredID.String())
+	has, err := ds.Has(ctx, retiredAddrsKey)
 	require.NoError(t, err)
-	verifyAd(t, ctx, subject, createAd(t, []byte("star"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeeraurc5vmmbt33jqiij27k2m4ajrcmlvitaohkbczgikctg4ajekieq", false, "baguqeerazskx6vqznzcjhuucvrmspr53dkrwkz3o7mhamdtdw6plrwsavuwq"), ad)
+	require.True(t, has)
 
-	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	gotAdCids, err := subject.NotifyRemoveProvider(ctx, retiredID)
 	require.NoError(t, err)
-	verifyAd(t, ctx, subject, createAd(t, []byte("ping"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeeraihesu6l6ob3c5gn3wwpmju356ius4354uh6aefmb3sfk3fnpjhdq", false, "baguqeeraqafrbk3ffaeaipcy7zms7wivqgj6l52f2xw7hwnl7w4qqhxrv2oq"), ad)
+	require.Len(t, gotAdCids, 2)
 
-	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	gotContextIDs := make(map[string]bool)
+	for i, adCid := range gotAdCids {
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		require.True(t, ad.IsRm)
+		require.Equal(t, retiredID.String(), ad.Provider)
+		gotContextIDs[string(ad.ContextID)] = true
+		if i > 0 {
+			require.Equal(t, gotAdCids[i-1], ad.PreviousID.(cidlink.Link).Cid)
+		}
+	}
+	require.Equal(t, map[string]bool{"fish": true, "sea/lion": true}, gotContextIDs)
+
+	gotLatestAdCid, _, err := subject.GetLatestAdv(ctx)
 	require.NoError(t, err)
-	verifyAd(t, ctx, subject, createAd(t, []byte("byte"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeera7k7x5kayh2yzp44wq6cd3i2z24o5rxyyedkwtgmwkaq63npcig4q", false, ""), ad)
+	require.Equal(t, gotAdCids[1], gotLatestAdCid)
 
-	// try to create a deplicate to make sure that they are processed correctly against the previously created datastore
-	_, err = subject.NotifyPut(ctx, nil, []byte("tree"), metadata.New(metadata.Bitswap{}))
+	// Assert the addresses of the retired provider are removed along with its context IDs.
+	has, err = ds.Has(ctx, retiredAddrsKey)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// Assert the retired provider has nothing left to remove.
+	_, err = subject.NotifyRemove(ctx, retiredID, []byte("fish"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+	_, err = subject.NotifyRemoveProvider(ctx, retiredID)
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+
+	// Assert the default provider is unaffected.
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("bird"), md)
 	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+}
 
-	mmap := make(map[string][]multihash.Multihash)
+func TestEngine_UpdateProviderAddrs(t *testing.T) {
+	ctx := contextWithTimeout(t)
 	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
 	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
-		if _, ok := mmap[string(contextID)]; !ok {
-			mmap[string(contextID)] = testutil.RandomMultihashes(t, rng, 42)
-		}
-		return provider.SliceMultihashIterator(mmap[string(contextID)]), nil
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 42)), nil
 	})
 
-	// publishing new add for the default provider
-	adId, err := subject.NotifyPut(ctx, nil, []byte("pong"), metadata.New(metadata.Bitswap{}))
+	md := metadata.New(metadata.Bitswap{})
+	putAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	putAd, err := subject.GetAdv(ctx, putAdCid)
 	require.NoError(t, err)
-	ad, _ = subject.GetAdv(ctx, adId)
-	require.Equal(t, existingRoot, ad.PreviousID.(cidlink.Link).Cid)
 
-	// try deleting record of the existing provider
-	_, err = subject.NotifyRemove(ctx, pID, []byte("star"))
+	// Assert advertising the same addresses is an error.
+	sameAddrs := make([]multiaddr.Multiaddr, 0, len(putAd.Addresses))
+	for _, s := range putAd.Addresses {
+		sameAddrs = append(sameAddrs, multiaddr.StringCast(s))
+	}
+	_, err = subject.UpdateProviderAddrs(ctx, peer.AddrInfo{Addrs: sameAddrs})
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+
+	newAddr := multiaddr.StringCast("/ip4/1.2.3.4/tcp/5678")
+	gotAdCid, err := subject.UpdateProviderAddrs(ctx, peer.AddrInfo{ID: subject.ProviderID(), Addrs: []multiaddr.Multiaddr{newAddr}})
 	require.NoError(t, err)
 
-	// try publishing for new provider
-	newPID := testutil.NewID(t)
-	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: newPID}, []byte("has"), metadata.New(metadata.Bitswap{}))
+	gotAd, err := subject.GetAdv(ctx, gotAdCid)
+	require.NoError(t, err)
+	require.False(t, gotAd.IsRm)
+	require.Equal(t, subject.ProviderID().String(), gotAd.Provider)
+	require.Equal(t, []string{newAddr.String()}, gotAd.Addresses)
+	require.Equal(t, schema.NoEntries, gotAd.Entries)
+	require.Empty(t, gotAd.ContextID)
+	require.Equal(t, putAd.Metadata, gotAd.Metadata)
+	require.Equal(t, putAdCid, gotAd.PreviousID.(cidlink.Link).Cid)
+	_, err = gotAd.VerifySignature()
 	require.NoError(t, err)
-}
 
-func verifyAd(t *testing.T, ctx context.Context, subject *engine.Engine, expected, actual *schema.Advertisement) {
-	require.Equal(t, expected.ContextID, actual.ContextID)
-	require.Equal(t, expected.Provider, actual.Provider)
-	require.Equal(t, expected.Addresses, actual.Addresses)
-	require.Equal(t, expected.Entries, actual.Entries)
-	require.Equal(t, expected.IsRm, actual.IsRm)
-	require.Equal(t, expected.PreviousID, actual.PreviousID)
-	if !actual.IsRm {
-		chunk, err := subject.Chunker().GetRawCachedChunk(ctx, actual.Entries)
-		require.NoError(t, err)
-		require.NotNil(t, chunk)
-	}
-}
+	gotLatestAdCid, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, gotAdCid, gotLatestAdCid)
 
-func contextWithTimeout(t *testing.T) context.Context {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	t.Cleanup(cancel)
-	return ctx
-}
+	_, err = subject.UpdateProviderAddrs(ctx, peer.AddrInfo{Addrs: []multiaddr.Multiaddr{newAddr}})
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
 
-func requireEqualLegsMessage(t *testing.T, got, want dtsync.Message) {
-	require.Equal(t, want.Cid, got.Cid)
-	require.Equal(t, want.ExtraData, got.ExtraData)
-	wantAddrs, err := want.GetAddrs()
+	// Assert subsequent advertisements for the default provider use the new addresses.
+	nextAdCid, err := subject.NotifyPut(ctx, nil, []byte("bird"), md)
 	require.NoError(t, err)
-	gotAddrs, err := got.GetAddrs()
+	nextAd, err := subject.GetAdv(ctx, nextAdCid)
 	require.NoError(t, err)
-	wantAddrsStr := multiAddsToString(wantAddrs)
-	sort.Strings(wantAddrsStr)
-	gotAddrsStr := multiAddsToString(gotAddrs)
-	sort.Strings(gotAddrsStr)
-	require.Equal(t, wantAddrsStr, gotAddrsStr)
+	require.Equal(t, []string{newAddr.String()}, nextAd.Addresses)
 }
 
-func multiAddsToString(addrs []multiaddr.Multiaddr) []string {
-	var rAddrs []string
-	for _, addr := range addrs {
-		rAddrs = append(rAddrs, addr.String())
-	}
-	return rAddrs
-}
+func TestEngine_EntriesCacheDatastoreIsSeparate(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	mhs := testutil.RandomMultihashes(t, rng, 42)
 
-func requireTrueEventually(t *testing.T, attempt func() bool, interval time.Duration, timeout time.Duration, msgAndArgs ...interface{}) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for {
-		if attempt() {
-			return
-		}
-		select {
-		case <-ctx.Done():
-			require.FailNow(t, "timed out awaiting eventual success", msgAndArgs...)
-			return
-		case <-ticker.C:
-		}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cacheDs := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithEntriesCacheDatastore(cacheDs))
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+
+	// Assert the entries are cached in the cache datastore only.
+	entriesKey := datastore.NewKey(ad.Entries.(cidlink.Link).Cid.String())
+	has, err := cacheDs.Has(ctx, entriesKey)
+	require.NoError(t, err)
+	require.True(t, has)
+	results, err := ds.Query(ctx, query.Query{Prefix: "/cache/links", KeysOnly: true})
+	require.NoError(t, err)
+	cached, err := results.Rest()
+	require.NoError(t, err)
+	require.Empty(t, cached)
+
+	// Assert the entries are served from the cache datastore.
+	n, err := subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, basicnode.Prototype.Any)
+	require.NoError(t, err)
+	require.NotNil(t, n)
+}
+
+func createAd(t *testing.T, contextID []byte, provider string, addrs []string, entries string, isRm bool, prevId string) *schema.Advertisement {
+	var prevLink ipld.Link
+	if prevId != "" {
+		p, err := cid.Parse(prevId)
+		require.NoError(t, err)
+		prevLink = cidlink.Link{Cid: p}
+	}
+	entriesCID, err := cid.Parse(entries)
+	require.NoError(t, err)
+	return &schema.Advertisement{ContextID: contextID, Provider: provider, Addresses: addrs, IsRm: isRm, PreviousID: prevLink, Entries: cidlink.Link{Cid: entriesCID}}
+}
+
+func TestEngine_Compact(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithCompactionGracePeriod(0))
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	_, _, err = subject.Compact(ctx)
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 42)), nil
+	})
+
+	md := metadata.New(metadata.Bitswap{})
+	fishAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	prevHead, err := subject.NotifyPut(ctx, nil, []byte("crab"), md)
+	require.NoError(t, err)
+
+	events, cancel := subject.Subscribe()
+	defer cancel()
+	adsPublished := promtestutil.ToFloat64(metrics.AdsPublished.WithLabelValues("put"))
+	head, count, err := subject.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// Assert the compacted advertisements are reported as a compaction, not as published.
+	require.Equal(t, adsPublished, promtestutil.ToFloat64(metrics.AdsPublished.WithLabelValues("put")))
+	require.Equal(t, engine.ChainCompactedEvent{Head: head, PrevHead: prevHead, Count: 2}, <-events)
+	require.Len(t, events, 0)
+
+	gotLatestAdCid, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, gotLatestAdCid)
+
+	// Assert the compacted chain contains one signed advertisement per live context ID.
+	gotContextIDs := make(map[string]struct{})
+	for c := head; c != cid.Undef; {
+		ad, err := subject.GetAdv(ctx, c)
+		require.NoError(t, err)
+		require.False(t, ad.IsRm)
+		require.NotEqual(t, schema.NoEntries, ad.Entries)
+		_, err = ad.VerifySignature()
+		require.NoError(t, err)
+		gotContextIDs[string(ad.ContextID)] = struct{}{}
+		if ad.PreviousID == nil {
+			break
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+	require.Equal(t, map[string]struct{}{"lobster": {}, "crab": {}}, gotContextIDs)
+
+	// Assert the retired chain is deleted since the grace period is zero.
+	_, err = subject.GetAdv(ctx, fishAdCid)
+	require.Error(t, err)
+
+	// Assert compacting again retains the advertisements shared by the retired and the new chain.
+	_, err = subject.NotifyPut(ctx, nil, []byte("shrimp"), md)
+	require.NoError(t, err)
+	head, count, err = subject.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	var gotCount int
+	for c := head; c != cid.Undef; {
+		ad, err := subject.GetAdv(ctx, c)
+		require.NoError(t, err)
+		gotCount++
+		if ad.PreviousID == nil {
+			break
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+	require.Equal(t, 3, gotCount)
+}
+
+func TestEngine_GC(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 42)), nil
+	})
+
+	md := metadata.New(metadata.Bitswap{})
+	fishAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fishAd, err := subject.GetAdv(ctx, fishAdCid)
+	require.NoError(t, err)
+	lobsterAdCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	lobsterAd, err := subject.GetAdv(ctx, lobsterAdCid)
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	// Store an advertisement that is not reachable from the chain, and a block that is not an
+	// advertisement.
+	orphanAd := *lobsterAd
+	orphanAd.ContextID = []byte("squid")
+	orphanNode, err := orphanAd.ToNode()
+	require.NoError(t, err)
+	store := &memstore.Store{}
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.SetWriteStorage(store)
+	orphanLnk, err := lsys.Store(ipld.LinkContext{}, schema.Linkproto, orphanNode)
+	require.NoError(t, err)
+	orphan := orphanLnk.(cidlink.Link).Cid
+	orphanBytes, err := store.Get(ctx, orphanLnk.Binary())
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(ctx, datastore.NewKey(orphan.String()), orphanBytes))
+	notAd := cid.NewCidV1(cid.DagJSON, testutil.RandomMultihashes(t, rng, 1)[0])
+	require.NoError(t, ds.Put(ctx, datastore.NewKey(notAd.String()), []byte("fish")))
+
+	cachedKey := func(l ipld.Link) datastore.Key {
+		return datastore.NewKey("/cache/links/" + l.(cidlink.Link).Cid.String())
+	}
+	requireCached := func(want bool, l ipld.Link) {
+		got, err := ds.Has(ctx, cachedKey(l))
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	requireCached(true, fishAd.Entries)
+	requireCached(true, lobsterAd.Entries)
+
+	// Assert dry run reports the garbage without deleting it.
+	var progress []engine.GCStats
+	stats, err := subject.GC(ctx, engine.WithGCDryRun(true), engine.WithGCProgress(func(s engine.GCStats) {
+		progress = append(progress, s)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, engine.GCStats{DryRun: true, LiveAds: 3, LiveEntries: 1, SweptAds: 1, SweptEntries: 1}, stats)
+	require.NotEmpty(t, progress)
+	require.Equal(t, stats, progress[len(progress)-1])
+	gotOrphan, err := ds.Has(ctx, datastore.NewKey(orphan.String()))
+	require.NoError(t, err)
+	require.True(t, gotOrphan)
+	requireCached(true, fishAd.Entries)
+
+	stats, err = subject.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, engine.GCStats{LiveAds: 3, LiveEntries: 1, SweptAds: 1, SweptEntries: 1}, stats)
+	gotOrphan, err = ds.Has(ctx, datastore.NewKey(orphan.String()))
+	require.NoError(t, err)
+	require.False(t, gotOrphan)
+	requireCached(false, fishAd.Entries)
+	requireCached(true, lobsterAd.Entries)
+
+	gotNotAd, err := ds.Has(ctx, datastore.NewKey(notAd.String()))
+	require.NoError(t, err)
+	require.True(t, gotNotAd)
+
+	// Assert the chain is intact and subsequent collections find no garbage.
+	_, err = subject.GetAdv(ctx, fishAdCid)
+	require.NoError(t, err)
+	stats, err = subject.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, engine.GCStats{LiveAds: 3, LiveEntries: 1}, stats)
+
+	// Assert nothing is swept if an advertisement in the chain is missing.
+	require.NoError(t, ds.Delete(ctx, datastore.NewKey(lobsterAdCid.String())))
+	_, err = subject.GC(ctx)
+	require.ErrorIs(t, err, engine.ErrIncompleteChain)
+	_, err = subject.GetAdv(ctx, fishAdCid)
+	require.NoError(t, err)
+}
+
+func TestEngine_Subscribe(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	ts, _ := newAnnounceServer(t, nil)
+	wantURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithPublisherKind(engine.DataTransferPublisher),
+		engine.WithTopicName(t.Name()),
+		engine.WithDirectAnnounce(ts.URL),
+		engine.WithEntriesCacheCapacity(1),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+
+	events, cancel := subject.Subscribe()
+	otherEvents, otherCancel := subject.Subscribe()
+	otherCancel()
+	_, ok := <-otherEvents
+	require.False(t, ok)
+
+	requireEvents := func(adCid cid.Cid, contextID string, want ...engine.Event) {
+		for _, w := range want {
+			var got engine.Event
+			select {
+			case got = <-events:
+			case <-ctx.Done():
+				require.FailNow(t, "timed out waiting for event")
+			}
+			require.IsType(t, w, got)
+			switch got := got.(type) {
+			case engine.EntriesChunkedEvent:
+				require.Equal(t, subject.ProviderID(), got.Provider)
+				require.Equal(t, []byte(contextID), got.ContextID)
+			case engine.AdStoredEvent:
+				require.Equal(t, adCid, got.AdCid)
+				require.Equal(t, subject.ProviderID(), got.Provider)
+				require.Equal(t, []byte(contextID), got.ContextID)
+				require.False(t, got.IsRm)
+			case engine.AdAnnouncedPubsubEvent:
+				require.Equal(t, adCid, got.AdCid)
+				require.NoError(t, got.Err)
+			case engine.AdAnnouncedHTTPEvent:
+				require.Equal(t, adCid, got.AdCid)
+				require.Equal(t, wantURL, got.URL)
+				require.NoError(t, got.Err)
+			case engine.CacheEvictedEvent:
+				require.Equal(t, w, got)
+			}
+		}
+	}
+
+	fishAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	requireEvents(fishAdCid, "fish",
+		engine.EntriesChunkedEvent{},
+		engine.AdStoredEvent{},
+		engine.AdAnnouncedPubsubEvent{},
+		engine.AdAnnouncedHTTPEvent{})
+
+	// Assert the fish entries are evicted from cache since the cache capacity is 1.
+	fishAd, err := subject.GetAdv(ctx, fishAdCid)
+	require.NoError(t, err)
+	birdAdCid, err := subject.NotifyPut(ctx, nil, []byte("bird"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	requireEvents(birdAdCid, "bird",
+		engine.CacheEvictedEvent{Root: fishAd.Entries.(cidlink.Link).Cid},
+		engine.EntriesChunkedEvent{},
+		engine.AdStoredEvent{},
+		engine.AdAnnouncedPubsubEvent{},
+		engine.AdAnnouncedHTTPEvent{})
+
+	cancel()
+	require.NoError(t, subject.Shutdown())
+}
+
+func TestEngine_SyncStatusOverHttp(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pubAddr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr(pubAddr),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+	notifyPut := func(contextID string) cid.Cid {
+		c, err := subject.NotifyPut(ctx, nil, []byte(contextID), metadata.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		return c
+	}
+	fetch := func(c cid.Cid) {
+		resp, err := http.Get("http://" + pubAddr + "/" + c.String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	fishAdCid := notifyPut("fish")
+	birdAdCid := notifyPut("bird")
+	birdAd, err := subject.GetAdv(ctx, birdAdCid)
+	require.NoError(t, err)
+
+	statuses, err := subject.SyncStatus(ctx)
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+
+	fetch(birdAdCid)
+	fetch(fishAdCid)
+	fetch(birdAd.Entries.(cidlink.Link).Cid)
+
+	statuses, err = subject.SyncStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, peer.ID(""), statuses[0].Peer)
+	require.Equal(t, birdAdCid, statuses[0].LastAdCid)
+	require.Equal(t, int64(1), statuses[0].EntriesBlocksServed)
+	require.Equal(t, 0, statuses[0].Lag)
+
+	// Assert lag grows as advertisements are published.
+	notifyPut("lobster")
+	statuses, err = subject.SyncStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, birdAdCid, statuses[0].LastAdCid)
+	require.Equal(t, 1, statuses[0].Lag)
+}
+
+func TestEngine_DatastoreBackwardsCompatibilityTest(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// copying testdata into the test dir
+	testutil.CopyDir(t, filepath.Join(testutil.ThisDir(t), "../testdata/datastore.ds"), tempDir)
+
+	ds, _ := leveldb.NewDatastore(tempDir, nil)
+	defer ds.Close()
+
+	// setting up engine with the configuration that was used to generate the datastore
+	ma1, _ := multiaddr.NewMultiaddr("/ip6/::1/tcp/62698")
+	ma2, _ := multiaddr.NewMultiaddr("/ip4/192.168.1.161/tcp/62695")
+	ma3, _ := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/62695")
+	pID, _ := peer.IDFromString("QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES")
+
+	ctx := contextWithTimeout(t)
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithProvider(peer.AddrInfo{ID: pID, Addrs: []multiaddr.Multiaddr{ma1, ma2, ma3}}))
+	require.NoError(t, err)
+	err = subject.Start(ctx)
+	require.NoError(t, err)
+	defer subject.Shutdown()
+
+	// walking back the ad chain
+	existingRoot, _ := cid.Parse("baguqeeraix5q35zho3z2x5hqsa2iga3372qj4txsr4ooc2zvbyownka57gzq")
+	ad, err := subject.GetAdv(ctx, existingRoot)
+	require.NoError(t, err)
+
+	verifyAd(t, ctx, subject, createAd(t, []byte("byte"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "bafkreehdwdcefgh4dqkjv67uzcmw7oje", true, "baguqeerahjpn2qtt3qwbzcwbytruxqtqjoitv7zv4d7dksppw7swl4rt3dqq"), ad)
+
+	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	verifyAd(t, ctx, subject, createAd(t, []byte("tree"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeerapsyb2pobz7vmcc2i4f5pe7chjs7bhgmfueqiuqwzsscmliwop45q", false, "baguqeerattk5otsdvvlpnno3a5x5ruowvjt7iqxtwqrwv5jhqbkq62mloc7a"), ad)
+
+	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	verifyAd(t, ctx, subject, createAd(t, []byte("star"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeeraurc5vmmbt33jqiij27k2m4ajrcmlvitaohkbczgikctg4ajekieq", false, "baguqeerazskx6vqznzcjhuucvrmspr53dkrwkz3o7mhamdtdw6plrwsavuwq"), ad)
+
+	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	verifyAd(t, ctx, subject, createAd(t, []byte("ping"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeeraihesu6l6ob3c5gn3wwpmju356ius4354uh6aefmb3sfk3fnpjhdq", false, "baguqeeraqafrbk3ffaeaipcy7zms7wivqgj6l52f2xw7hwnl7w4qqhxrv2oq"), ad)
+
+	ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	verifyAd(t, ctx, subject, createAd(t, []byte("byte"), "QmPxKFBM2A7VZURXZhZLCpEnhMFtZ7WSZwFLneFEiYneES", []string{"/ip6/::1/tcp/62698", "/ip4/192.168.1.161/tcp/62695", "/ip4/127.0.0.1/tcp/62695"}, "baguqeera7k7x5kayh2yzp44wq6cd3i2z24o5rxyyedkwtgmwkaq63npcig4q", false, ""), ad)
+
+	// try to create a deplicate to make sure that they are processed correctly against the previously created datastore
+	_, err = subject.NotifyPut(ctx, nil, []byte("tree"), metadata.New(metadata.Bitswap{}))
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+
+	mmap := make(map[string][]multihash.Multihash)
+	rng := rand.New(rand.NewSource(1413))
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if _, ok := mmap[string(contextID)]; !ok {
+			mmap[string(contextID)] = testutil.RandomMultihashes(t, rng, 42)
+		}
+		return provider.SliceMultihashIterator(mmap[string(contextID)]), nil
+	})
+
+	// publishing new add for the default provider
+	adId, err := subject.NotifyPut(ctx, nil, []byte("pong"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, _ = subject.GetAdv(ctx, adId)
+	require.Equal(t, existingRoot, ad.PreviousID.(cidlink.Link).Cid)
+
+	// try deleting record of the existing provider
+	_, err = subject.NotifyRemove(ctx, pID, []byte("star"))
+	require.NoError(t, err)
+
+	// try publishing for new provider
+	newPID := testutil.NewID(t)
+	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: newPID}, []byte("has"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+}
+
+func verifyAd(t *testing.T, ctx context.Context, subject *engine.Engine, expected, actual *schema.Advertisement) {
+	require.Equal(t, expected.ContextID, actual.ContextID)
+	require.Equal(t, expected.Provider, actual.Provider)
+	require.Equal(t, expected.Addresses, actual.Addresses)
+	require.Equal(t, expected.Entries, actual.Entries)
+	require.Equal(t, expected.IsRm, actual.IsRm)
+	require.Equal(t, expected.PreviousID, actual.PreviousID)
+	if !actual.IsRm {
+		chunk, err := subject.Chunker().GetRawCachedChunk(ctx, actual.Entries)
+		require.NoError(t, err)
+		require.NotNil(t, chunk)
+	}
+}
+
+func contextWithTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func requireEqualLegsMessage(t *testing.T, got, want dtsync.Message) {
+	require.Equal(t, want.Cid, got.Cid)
+	require.Equal(t, want.ExtraData, got.ExtraData)
+	wantAddrs, err := want.GetAddrs()
+	require.NoError(t, err)
+	gotAddrs, err := got.GetAddrs()
+	require.NoError(t, err)
+	wantAddrsStr := multiAddsToString(wantAddrs)
+	sort.Strings(wantAddrsStr)
+	gotAddrsStr := multiAddsToString(gotAddrs)
+	sort.Strings(gotAddrsStr)
+	require.Equal(t, wantAddrsStr, gotAddrsStr)
+}
+
+// newAnnounceServer starts an HTTP server that accepts direct announcements, and returns it along
+// with the channel on which the announcement messages it accepts are received. If reject is not
+// nil, the server responds with an error to the announcements for which it returns true.
+func newAnnounceServer(t *testing.T, reject func() bool) (*httptest.Server, <-chan dtsync.Message) {
+	announced := make(chan dtsync.Message, 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if reject != nil && reject() {
+			http.Error(w, "indexer is down", http.StatusServiceUnavailable)
+			return
+		}
+		an := dtsync.Message{}
+		if err := an.UnmarshalCBOR(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		announced <- an
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, announced
+}
+
+func multiAddsToString(addrs []multiaddr.Multiaddr) []string {
+	var rAddrs []string
+	for _, addr := range addrs {
+		rAddrs = append(rAddrs, addr.String())
+	}
+	return rAddrs
+}
+
+func requireTrueEventually(t *testing.T, attempt func() bool, interval time.Duration, timeout time.Duration, msgAndArgs ...interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if attempt() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			require.FailNow(t, "timed out awaiting eventual success", msgAndArgs...)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func TestEngine_AnnounceRetry(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	var healthy int32
+	var announces int32
+	ts, _ := newAnnounceServer(t, func() bool {
+		atomic.AddInt32(&announces, 1)
+		return atomic.LoadInt32(&healthy) == 0
+	})
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithPublisherKind(engine.DataTransferPublisher),
+		engine.WithTopicName(t.Name()),
+		engine.WithDirectAnnounce(ts.URL),
+		engine.WithAnnounceRetry(10*time.Millisecond, 20*time.Millisecond, time.Minute),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+
+	// Publishing must not fail when the indexer is down.
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	retries, err := subject.AnnounceRetries(ctx)
+	require.NoError(t, err)
+	require.Len(t, retries, 1)
+	require.Equal(t, ts.URL, retries[0].URL)
+	require.Equal(t, adCid, retries[0].AdCid)
+	require.NotEmpty(t, retries[0].LastErr)
+
+	// Wait for the announcement to be retried at least once more.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&announces) > 2
+	}, 10*time.Second, 10*time.Millisecond)
+	retries, err = subject.AnnounceRetries(ctx)
+	require.NoError(t, err)
+	require.Len(t, retries, 1)
+	require.Greater(t, retries[0].Attempts, 1)
+
+	atomic.StoreInt32(&healthy, 1)
+	require.Eventually(t, func() bool {
+		retries, err := subject.AnnounceRetries(ctx)
+		require.NoError(t, err)
+		return len(retries) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestEngine_AddRemoveAnnounceURL(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	ts, announced := newAnnounceServer(t, nil)
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithPublisherKind(engine.DataTransferPublisher),
+		engine.WithTopicName(t.Name()),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+	require.Empty(t, subject.AnnounceURLs())
+
+	require.True(t, subject.AddAnnounceURL(u))
+	require.False(t, subject.AddAnnounceURL(u))
+	require.Equal(t, []*url.URL{u}, subject.AnnounceURLs())
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Len(t, announced, 1)
+
+	removed, err := subject.RemoveAnnounceURL(ctx, u)
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = subject.RemoveAnnounceURL(ctx, u)
+	require.NoError(t, err)
+	require.False(t, removed)
+	require.Empty(t, subject.AnnounceURLs())
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Len(t, announced, 1)
+}
+
+func TestEngine_SyncPolicyGatesHttpPublisher(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pubAddr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	pol, err := policy.New(false, nil)
+	require.NoError(t, err)
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr(pubAddr),
+		engine.WithSyncPolicy(pol),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	require.Same(t, pol, subject.SyncPolicy())
+
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 10)), nil
+	})
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	fetch := func() int {
+		resp, err := http.Get("http://" + pubAddr + "/" + adCid.String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Anonymous syncs over HTTP are denied unless peers are allowed by default.
+	require.NotEqual(t, http.StatusOK, fetch())
+	pol.Copy(func() *policy.Policy {
+		p, err := policy.New(true, nil)
+		require.NoError(t, err)
+		return p
+	}())
+	require.Equal(t, http.StatusOK, fetch())
+}
+
+func TestEngine_GetContextState(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+
+	_, err = subject.GetContextState(ctx, "", []byte("fish"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+
+	md := metadata.New(metadata.Bitswap{})
+	fishAd, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+
+	state, err := subject.GetContextState(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, fishAd)
+	require.NoError(t, err)
+	require.Equal(t, subject.Host().ID(), state.Provider)
+	require.Equal(t, []byte("fish"), state.ContextID)
+	require.Equal(t, ad.Entries.(cidlink.Link).Cid, state.EntriesCid)
+	require.True(t, md.Equal(state.Metadata))
+	require.False(t, state.IsRm)
+	require.Equal(t, fishAd, state.LastAdCid)
+
+	rmAd, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	state, err = subject.GetContextState(ctx, subject.Host().ID(), []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, state.EntriesCid)
+	require.True(t, state.IsRm)
+	require.Equal(t, rmAd, state.LastAdCid)
+
+	_, err = subject.GetContextState(ctx, "", []byte("crab"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+}
+
+func TestEngine_ListContextIDs(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+
+	list := func(p peer.ID) []string {
+		iter, err := subject.ListContextIDs(ctx, p)
+		require.NoError(t, err)
+		defer iter.Close()
+		var contextIDs []string
+		for {
+			contextID, entriesCid, err := iter.Next()
+			if err == io.EOF {
+				return contextIDs
+			}
+			require.NoError(t, err)
+			require.NotEqual(t, cid.Undef, entriesCid)
+			contextIDs = append(contextIDs, string(contextID))
+		}
+	}
+	require.Empty(t, list(""))
+
+	md := metadata.New(metadata.Bitswap{})
+	for _, contextID := range []string{"lobster", "fish", "crab"} {
+		_, err = subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+	otherAddrs, _ := multiaddr.NewMultiaddr("/ip4/0.0.0.0/tcp/1234/http")
+	other := &peer.AddrInfo{ID: testutil.NewID(t), Addrs: []multiaddr.Multiaddr{otherAddrs}}
+	_, err = subject.NotifyPut(ctx, other, []byte("squid"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"crab", "lobster"}, list(""))
+	require.Equal(t, []string{"crab", "lobster"}, list(subject.Host().ID()))
+	require.Equal(t, []string{"squid"}, list(other.ID))
+}
+
+func TestEngine_ConsistencyCheckRepairsMappings(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	}
+	md := metadata.New(metadata.Bitswap{})
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(lister)
+	fishAd, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	require.NoError(t, subject.Shutdown())
+
+	// Simulate the mappings written by notifies that crashed before storing their advertisement:
+	// one for a new context ID, and one that changed the metadata of an existing context ID.
+	entries := testutil.RandomCids(t, rng, 1)[0]
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyCid/lobster"), entries.Bytes()))
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyMD/lobster"), mdBytes))
+	otherMd := metadata.New(metadata.Bitswap{}, &metadata.GraphsyncFilecoinV1{PieceCID: entries})
+	otherMdBytes, err := otherMd.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyMD/fish"), otherMdBytes))
+
+	// Unless repair is enabled, the mappings are only reported.
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithConsistencyCheck(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	require.NoError(t, subject.Shutdown())
+	has, err := ds.Has(ctx, datastore.NewKey("map/keyCid/lobster"))
+	require.NoError(t, err)
+	require.True(t, has)
+
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithConsistencyRepair(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	_, err = subject.GetContextState(ctx, "", []byte("lobster"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+	state, err := subject.GetContextState(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, md.Equal(state.Metadata))
+	require.Equal(t, fishAd, state.LastAdCid)
+
+	// The context IDs can be advertised as if the crashed notifies never happened.
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), otherMd)
+	require.NoError(t, err)
+}
+
+func TestEngine_ConsistencyRepairSkipsIncompleteChain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	}
+	md := metadata.New(metadata.Bitswap{})
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(lister)
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+	require.NoError(t, subject.Shutdown())
+
+	// Lose an advertisement in the middle of the chain, and leave a mapping with no advertisement.
+	require.NoError(t, ds.Delete(ctx, datastore.NewKey(adCids[1].String())))
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyCid/squid"), testutil.RandomCids(t, rng, 1)[0].Bytes()))
+
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithConsistencyRepair(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	// No mappings are deleted, since the advertisements beyond the gap cannot be checked.
+	for _, contextID := range []string{"fish", "lobster", "crab", "squid"} {
+		has, err := ds.Has(ctx, datastore.NewKey("map/keyCid/"+contextID))
+		require.NoError(t, err)
+		require.True(t, has, contextID)
+	}
+}
+
+func TestEngine_VerifyChain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	report, err := subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Equal(t, cid.Undef, report.Head)
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    testutil.RandomMultihashes(t, rng, 5),
+		"lobster": testutil.RandomMultihashes(t, rng, 5),
+		"crab":    testutil.RandomMultihashes(t, rng, 5),
+	}
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	})
+	md := metadata.New(metadata.Bitswap{})
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+	rmAdCid, err := subject.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+
+	report, err = subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.True(t, report.OK(), "%v", report.Problems)
+	require.Equal(t, rmAdCid, report.Head)
+	require.Equal(t, 4, report.Advertisements)
+	require.Equal(t, 2, report.Mappings)
+
+	// A mapping of a context ID that was never advertised is reported.
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyCid/squid"), testutil.RandomCids(t, rng, 1)[0].Bytes()))
+	report, err = subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 1)
+	require.Equal(t, cid.Undef, report.Problems[0].AdCid)
+	require.Equal(t, []byte("squid"), report.Problems[0].ContextID)
+
+	// A corrupt advertisement is reported, and cuts off the rest of the chain along with the
+	// context IDs advertised in it.
+	require.NoError(t, ds.Put(ctx, datastore.NewKey(adCids[1].String()), []byte("fish")))
+	report, err = subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Advertisements)
+	require.Len(t, report.Problems, 4)
+	require.Equal(t, adCids[1], report.Problems[0].AdCid)
+	var unreferenced []string
+	for _, problem := range report.Problems[1:] {
+		require.Equal(t, cid.Undef, problem.AdCid)
+		unreferenced = append(unreferenced, string(problem.ContextID))
+	}
+	require.ElementsMatch(t, []string{"fish", "lobster", "squid"}, unreferenced)
+}
+
+func TestEngine_ExportImportChain(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    testutil.RandomMultihashes(t, rng, 5),
+		"lobster": testutil.RandomMultihashes(t, rng, 5),
+	}
+	lister := func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	}
+	md := metadata.New(metadata.Bitswap{})
+
+	source, err := engine.New(engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, source.Start(ctx))
+	source.RegisterMultihashLister(lister)
+
+	carPath := filepath.Join(t.TempDir(), "chain.car")
+	_, _, err = source.ExportChain(ctx, carPath, true)
+	require.Equal(t, engine.ErrNoAdvertisements, err)
+
+	_, err = source.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	lobsterAdCid, err := source.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	_, err = source.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	head, count, err := source.ExportChain(ctx, carPath, true)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	_, _, err = source.ExportChain(ctx, carPath, true)
+	require.Error(t, err)
+	require.NoError(t, source.Shutdown())
+
+	// Only the entries of lobster are exported, since fish is removed.
+	bs, err := carblockstore.OpenReadOnly(carPath)
+	require.NoError(t, err)
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{head}, roots)
+	keys, err := bs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var blockCount int
+	for range keys {
+		blockCount++
+	}
+	require.Equal(t, 4, blockCount)
+
+	// Write a chain that is missing its first advertisement.
+	brokenCarPath := filepath.Join(t.TempDir(), "broken.car")
+	brokenBs, err := carblockstore.OpenReadWrite(brokenCarPath, []cid.Cid{head})
+	require.NoError(t, err)
+	for _, c := range []cid.Cid{head, lobsterAdCid} {
+		blk, err := bs.Get(ctx, c)
+		require.NoError(t, err)
+		require.NoError(t, brokenBs.Put(ctx, blk))
+	}
+	require.NoError(t, brokenBs.Finalize())
+	require.NoError(t, bs.Close())
+
+	// A chain signed by a different identity is rejected without importing anything.
+	other, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, other.Start(ctx))
+	_, _, err = other.ImportChain(ctx, carPath)
+	require.ErrorIs(t, err, engine.ErrChainSignerMismatch)
+	gotLatest, _, err := other.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, gotLatest)
+	_, err = other.GetAdv(ctx, head)
+	require.Error(t, err)
+	require.NoError(t, other.Shutdown())
+
+	subject, err := engine.New(engine.WithHost(h), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	// An incomplete chain is not marked as the latest, and the mappings committed before the
+	// missing advertisement is found are removed.
+	engine.SetImportBatchSize(t, 1)
+	_, _, err = subject.ImportChain(ctx, brokenCarPath)
+	require.Error(t, err)
+	gotLatest, _, err = subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, gotLatest)
+	_, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+
+	gotHead, gotCount, err := subject.ImportChain(ctx, carPath)
+	require.NoError(t, err)
+	require.Equal(t, head, gotHead)
+	require.Equal(t, 3, gotCount)
+	_, _, err = subject.ImportChain(ctx, carPath)
+	require.Equal(t, engine.ErrChainExists, err)
+
+	report, err := subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.True(t, report.OK(), "%v", report.Problems)
+	require.Equal(t, 1, report.Mappings)
+
+	// The imported context IDs can be removed, and new ones published on top of the chain.
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.Equal(t, provider.ErrAlreadyAdvertised, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+	rmAdCid, err := subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	rmAd, err := subject.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	require.Equal(t, head, rmAd.PreviousID.(cidlink.Link).Cid)
+}
+
+// countingSigner is a stand-in for a signing service that records the advertisements it signs.
+type countingSigner struct {
+	engine.Signer
+	signed int32
+}
+
+func (s *countingSigner) Sign(ctx context.Context, ad *schema.Advertisement) error {
+	atomic.AddInt32(&s.signed, 1)
+	return s.Signer.Sign(ctx, ad)
+}
+
+func TestEngine_WithSigner(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+	// Sign with a key other than the host key on behalf of the provider that owns it.
+	p, err := libp2p.New()
+	require.NoError(t, err)
+	defer p.Close()
+	keySigner, err := engine.NewKeySigner(p.Peerstore().PrivKey(p.ID()))
+	require.NoError(t, err)
+	signer := &countingSigner{Signer: keySigner}
+
+	subject, err := engine.New(
+		engine.WithHost(h),
+		engine.WithProvider(peer.AddrInfo{ID: p.ID(), Addrs: p.Addrs()}),
+		engine.WithSigner(signer),
+		engine.WithPublisherKind(engine.NoPublisher),
+	)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&signer.signed))
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	signerID, err := ad.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, p.ID(), signerID)
+}
+
+// keylessHost is a libp2p host whose private key is not available locally.
+type keylessHost struct {
+	host.Host
+}
+
+func (h keylessHost) Peerstore() peerstore.Peerstore {
+	return keylessPeerstore{h.Host.Peerstore()}
+}
+
+type keylessPeerstore struct {
+	peerstore.Peerstore
+}
+
+func (keylessPeerstore) PrivKey(peer.ID) crypto.PrivKey {
+	return nil
+}
+
+func TestEngine_WithSignerDoesNotRequireHostKey(t *testing.T) {
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+	signer, err := engine.NewKeySigner(h.Peerstore().PrivKey(h.ID()))
+	require.NoError(t, err)
+
+	_, err = engine.New(engine.WithHost(keylessHost{h}))
+	require.Error(t, err)
+	_, err = engine.New(engine.WithHost(keylessHost{h}), engine.WithSigner(signer))
+	require.NoError(t, err)
+	// The HTTP publisher signs the head of the chain using the host key.
+	_, err = engine.New(engine.WithHost(keylessHost{h}), engine.WithSigner(signer), engine.WithPublisherKind(engine.HttpPublisher))
+	require.Error(t, err)
+}
+
+func TestEngine_HandOverToNewDefaultProvider(t *testing.T) {
+	ctx := contextWithTimeout(t)
+	rng := rand.New(rand.NewSource(1413))
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	md := metadata.New(metadata.Bitswap{})
+	lister := func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
 	}
+
+	prevHost, err := libp2p.New()
+	require.NoError(t, err)
+	defer prevHost.Close()
+	prev, err := engine.New(engine.WithDatastore(ds), engine.WithHost(prevHost), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, prev.Start(ctx))
+	prev.RegisterMultihashLister(lister)
+	head, err := prev.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	require.NoError(t, prev.Shutdown())
+
+	// Restart on the same datastore with a rotated identity, retaining the previous key.
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+	signer, err := engine.NewKeySigner(h.Peerstore().PrivKey(h.ID()), prevHost.Peerstore().PrivKey(prevHost.ID()))
+	require.NoError(t, err)
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithSigner(signer), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(lister)
+
+	// A hand-over advertisement by the new provider is published on top of the chain.
+	handOverCid, handOverAd, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, head, handOverCid)
+	require.Equal(t, head, handOverAd.PreviousID.(cidlink.Link).Cid)
+	require.Equal(t, h.ID().String(), handOverAd.Provider)
+	require.Equal(t, schema.NoEntries, handOverAd.Entries)
+	signerID, err := handOverAd.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, h.ID(), signerID)
+
+	// The hand-over happens only once.
+	require.NoError(t, subject.Shutdown())
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(h), engine.WithSigner(signer), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+	latest, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, handOverCid, latest)
+
+	// The content advertised so far remains attributed to the previous provider.
+	it, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	_, _, err = it.Next()
+	require.Equal(t, io.EOF, err)
+	require.NoError(t, it.Close())
+	it, err = subject.ListContextIDs(ctx, prevHost.ID())
+	require.NoError(t, err)
+	contextID, _, err := it.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish"), contextID)
+	require.NoError(t, it.Close())
+
+	report, err := subject.VerifyChain(ctx)
+	require.NoError(t, err)
+	require.True(t, report.OK(), "%v", report.Problems)
+
+	// Content of the previous provider is removed by an advertisement signed with its key, and
+	// content of the new provider is advertised independently of it.
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.Equal(t, provider.ErrContextIDNotFound, err)
+	rmAdCid, err := subject.NotifyRemove(ctx, prevHost.ID(), []byte("fish"))
+	require.NoError(t, err)
+	rmAd, err := subject.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	signerID, err = rmAd.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, prevHost.ID(), signerID)
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, h.ID().String(), ad.Provider)
 }
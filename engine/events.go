@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-legs/dtsync"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// eventsBufferSize is the number of events buffered per subscriber before events are dropped.
+const eventsBufferSize = 64
+
+type (
+	// Event is an event emitted by the engine to subscribers. Its concrete type is one of:
+	//  - AdStoredEvent
+	//  - AdAnnouncedPubsubEvent
+	//  - AdAnnouncedHTTPEvent
+	//  - EntriesChunkedEvent
+	//  - CacheEvictedEvent
+	//  - IndexerSyncedEvent
+	//  - ChainCompactedEvent
+	//
+	// See: Engine.Subscribe.
+	Event interface {
+		event()
+	}
+
+	// AdStoredEvent is emitted when an advertisement is stored in the local link system. It is not
+	// emitted for the advertisements that are re-generated by compaction; see ChainCompactedEvent.
+	AdStoredEvent struct {
+		// AdCid is the CID of the stored advertisement.
+		AdCid cid.Cid
+		// Provider is the ID of the provider the advertisement is for.
+		Provider peer.ID
+		// ContextID is the context ID of the advertisement.
+		ContextID []byte
+		// IsRm signals whether the advertisement is a removal advertisement.
+		IsRm bool
+	}
+
+	// AdAnnouncedPubsubEvent is emitted when the publisher is notified of a new latest
+	// advertisement, which in turn announces it over gossipsub if the publisher kind supports it.
+	AdAnnouncedPubsubEvent struct {
+		// AdCid is the CID of the announced advertisement.
+		AdCid cid.Cid
+		// Err is the error that occurred while announcing, if any.
+		Err error
+	}
+
+	// AdAnnouncedHTTPEvent is emitted once per indexer URL to which an advertisement is announced
+	// directly over HTTP.
+	AdAnnouncedHTTPEvent struct {
+		// AdCid is the CID of the announced advertisement.
+		AdCid cid.Cid
+		// URL is the URL of the indexer to which the announcement was sent.
+		URL *url.URL
+		// Err is the error that occurred while announcing, if any.
+		Err error
+	}
+
+	// EntriesChunkedEvent is emitted when the multihashes of a context ID are chunked into an
+	// entries DAG, either as part of publishing an advertisement or when regenerating the chunks
+	// that were evicted from cache.
+	EntriesChunkedEvent struct {
+		// Root is the CID of the root of the entries DAG.
+		Root cid.Cid
+		// Provider is the ID of the provider the entries are for.
+		Provider peer.ID
+		// ContextID is the context ID of the entries.
+		ContextID []byte
+	}
+
+	// CacheEvictedEvent is emitted when an entries DAG is evicted from the entries cache.
+	CacheEvictedEvent struct {
+		// Root is the CID of the root of the evicted entries DAG.
+		Root cid.Cid
+	}
+
+	// IndexerSyncedEvent is emitted when a peer completes syncing a DAG from the engine via the
+	// data transfer publisher. This event is only emitted if the engine is instantiated with an
+	// existing data transfer manager; see WithDataTransfer.
+	IndexerSyncedEvent struct {
+		// Peer is the ID of the peer that synced.
+		Peer peer.ID
+		// Cid is the root of the DAG that was synced, i.e. an advertisement or an entries DAG.
+		Cid cid.Cid
+	}
+
+	// ChainCompactedEvent is emitted when the advertisement chain is replaced by its compacted
+	// version. See: Engine.Compact.
+	ChainCompactedEvent struct {
+		// Head is the CID of the head of the compacted chain.
+		Head cid.Cid
+		// PrevHead is the CID of the head of the replaced chain.
+		PrevHead cid.Cid
+		// Count is the number of advertisements in the compacted chain.
+		Count int
+	}
+)
+
+func (AdStoredEvent) event()          {}
+func (AdAnnouncedPubsubEvent) event() {}
+func (AdAnnouncedHTTPEvent) event()   {}
+func (EntriesChunkedEvent) event()    {}
+func (CacheEvictedEvent) event()      {}
+func (IndexerSyncedEvent) event()     {}
+func (ChainCompactedEvent) event()    {}
+
+// eventBus distributes the events emitted by the engine to subscribers.
+type eventBus struct {
+	lock sync.Mutex
+	subs []chan Event
+}
+
+// Subscribe creates a channel that receives the events emitted by the engine, and adds that
+// channel to the list of subscribers.
+//
+// The channel is buffered; events are dropped for a subscriber that does not keep up with the
+// rate at which events are emitted, so that the engine is never blocked by a slow subscriber.
+//
+// Calling the returned cancel function removes the channel from the list of subscribers and
+// closes it. The channel is also closed when the engine is shut down.
+func (e *Engine) Subscribe() (<-chan Event, context.CancelFunc) {
+	ch := make(chan Event, eventsBufferSize)
+	e.events.lock.Lock()
+	e.events.subs = append(e.events.subs, ch)
+	e.events.lock.Unlock()
+
+	cancel := func() {
+		e.events.lock.Lock()
+		defer e.events.lock.Unlock()
+		for i, sub := range e.events.subs {
+			if sub == ch {
+				e.events.subs[i] = e.events.subs[len(e.events.subs)-1]
+				e.events.subs[len(e.events.subs)-1] = nil
+				e.events.subs = e.events.subs[:len(e.events.subs)-1]
+				close(ch)
+				break
+			}
+		}
+	}
+	return ch, cancel
+}
+
+// emit sends the given event to all subscribers without blocking.
+func (e *Engine) emit(ev Event) {
+	e.events.lock.Lock()
+	defer e.events.lock.Unlock()
+	for _, sub := range e.events.subs {
+		select {
+		case sub <- ev:
+		default:
+			log.Warnw("Dropped event for slow subscriber", "event", ev)
+		}
+	}
+}
+
+// closeSubscriptions closes the channels of all subscribers.
+func (e *Engine) closeSubscriptions() {
+	e.events.lock.Lock()
+	defer e.events.lock.Unlock()
+	for _, sub := range e.events.subs {
+		close(sub)
+	}
+	e.events.subs = nil
+}
+
+// onDataTransferEvent records the sync status of, and emits IndexerSyncedEvent for, every completed
+// go-legs data transfer served by the engine.
+func (e *Engine) onDataTransferEvent(event datatransfer.Event, channelState datatransfer.ChannelState) {
+	if event.Code != datatransfer.Complete {
+		return
+	}
+	// Ignore transfers that are not go-legs syncs from this engine's host.
+	if _, ok := channelState.Voucher().(*dtsync.Voucher); !ok {
+		return
+	}
+	if channelState.Sender() != e.h.ID() {
+		return
+	}
+	e.trackSyncedDAG(context.Background(), channelState.Recipient(), channelState.BaseCID(), channelState.SentCidsTotal())
+	e.emit(IndexerSyncedEvent{Peer: channelState.Recipient(), Cid: channelState.BaseCID()})
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// gcProgressInterval is the number of blocks swept between progress reports.
+const gcProgressInterval = 1000
+
+type (
+	// GCStats captures the progress and outcome of a garbage collection run.
+	GCStats struct {
+		// DryRun signals whether the swept blocks were only counted but not deleted.
+		DryRun bool
+		// LiveAds is the number of advertisements reachable from the latest advertisement, or from
+		// the chains retired by compaction that are still within their grace period.
+		LiveAds int
+		// LiveEntries is the number of entries DAG roots referenced by the context ID mappings.
+		LiveEntries int
+		// SweptAds is the number of unreachable advertisement blocks swept so far.
+		SweptAds int
+		// SweptEntries is the number of unreferenced cached entries DAGs swept so far.
+		SweptEntries int
+	}
+
+	// GCOption sets a configuration parameter for Engine.GC.
+	GCOption func(*gcOptions)
+
+	gcOptions struct {
+		dryRun   bool
+		progress func(GCStats)
+	}
+)
+
+// WithGCDryRun sets whether garbage collection should only count the blocks that would be swept
+// without deleting them. Defaults to false.
+func WithGCDryRun(dryRun bool) GCOption {
+	return func(o *gcOptions) {
+		o.dryRun = dryRun
+	}
+}
+
+// WithGCProgress sets the function called to report the progress of garbage collection. It is
+// called once marking is complete, periodically while sweeping, and once sweeping is complete.
+func WithGCProgress(fn func(GCStats)) GCOption {
+	return func(o *gcOptions) {
+		o.progress = fn
+	}
+}
+
+// GC deletes the advertisement and cached entries blocks stored by the engine that are no longer
+// reachable, so that the datastore does not grow without bound.
+//
+// Blocks are marked as reachable if they are:
+//  - advertisements in the chain from the latest advertisement,
+//  - advertisements in chains retired by Engine.Compact that are still within their grace period,
+//    or
+//  - cached entries DAGs whose root is referenced by a context ID currently advertised.
+//
+// Every other advertisement block in the datastore, and every other cached entries DAG is swept.
+// Notably, this includes the entries of context IDs removed via Engine.NotifyRemove. Chunks that
+// are shared with a reachable entries DAG are retained.
+//
+// Blocks that do not decode as advertisements are never swept. ErrIncompleteChain is returned if
+// an advertisement in a marked chain is missing, in which case nothing is swept; see
+// Engine.VerifyChain.
+//
+// Advertisements cannot be published while garbage is collected; calls that publish are blocked
+// until GC returns. The returned stats capture the number of blocks swept, or the ones that would
+// be swept if WithGCDryRun is set.
+func (e *Engine) GC(ctx context.Context, o ...GCOption) (GCStats, error) {
+	var opts gcOptions
+	for _, apply := range o {
+		apply(&opts)
+	}
+	report := func(GCStats) {}
+	if opts.progress != nil {
+		report = opts.progress
+	}
+
+	e.gcLk.Lock()
+	defer e.gcLk.Unlock()
+
+	stats := GCStats{DryRun: opts.dryRun}
+	log := log.With("dryRun", opts.dryRun)
+	log.Info("Collecting garbage")
+	start := time.Now()
+
+	liveAds, err := e.markAds(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to mark reachable advertisements: %w", err)
+	}
+	stats.LiveAds = len(liveAds)
+	liveEntries := make(map[cid.Cid]struct{})
+	err = e.forEachKeyCidMapping(ctx, func(_ peer.ID, _ []byte, c cid.Cid) error {
+		liveEntries[c] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to mark referenced entries: %w", err)
+	}
+	stats.LiveEntries = len(liveEntries)
+	log.Infow("Marked reachable blocks", "liveAds", stats.LiveAds, "liveEntries", stats.LiveEntries)
+	report(stats)
+
+	// Collect the keys to sweep first, since deleting while querying is not safe across datastores.
+	// Only blocks that are advertisements are swept, since the datastore may be shared with blocks
+	// not written by the engine.
+	var sweepAds []datastore.Key
+	lsys := e.vanillaLinkSystem()
+	err = e.forEachBlockKey(ctx, func(k datastore.Key, c cid.Cid) {
+		if _, ok := liveAds[c]; ok {
+			return
+		}
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			log.Debugw("Skipped block that is not an advertisement", "key", k, "err", err)
+			return
+		}
+		if _, err = schema.UnwrapAdvertisement(n); err != nil {
+			log.Debugw("Skipped block that is not an advertisement", "key", k, "err", err)
+			return
+		}
+		sweepAds = append(sweepAds, k)
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to list advertisement blocks: %w", err)
+	}
+	for _, k := range sweepAds {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		if !opts.dryRun {
+			if err := e.ds.Delete(ctx, k); err != nil {
+				return stats, fmt.Errorf("failed to delete advertisement block %s: %w", k, err)
+			}
+		}
+		stats.SweptAds++
+		if stats.SweptAds%gcProgressInterval == 0 {
+			report(stats)
+		}
+	}
+
+	roots, err := e.entriesChunker.Roots(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list cached entries: %w", err)
+	}
+	for _, root := range roots {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		if _, ok := liveEntries[root.(cidlink.Link).Cid]; ok {
+			continue
+		}
+		if !opts.dryRun {
+			if _, err := e.entriesChunker.Evict(ctx, root); err != nil {
+				return stats, fmt.Errorf("failed to evict cached entries %s: %w", root, err)
+			}
+		}
+		stats.SweptEntries++
+		if stats.SweptEntries%gcProgressInterval == 0 {
+			report(stats)
+		}
+	}
+
+	if !opts.dryRun {
+		if err := e.ds.Sync(ctx, datastore.NewKey("/")); err != nil {
+			return stats, err
+		}
+	}
+	log.Infow("Collected garbage", "sweptAds", stats.SweptAds, "sweptEntries", stats.SweptEntries, "took", time.Since(start))
+	report(stats)
+	return stats, nil
+}
+
+// markAds returns the set of advertisements reachable from the latest advertisement, and from the
+// chains retired by compaction that are still within their grace period.
+func (e *Engine) markAds(ctx context.Context) (map[cid.Cid]struct{}, error) {
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	heads := []cid.Cid{head}
+	err = e.forEachRetiredChain(ctx, func(_ datastore.Key, rc retiredChain) error {
+		if time.Since(rc.RetiredAt) < e.compactionGracePeriod {
+			heads = append(heads, rc.Head)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[cid.Cid]struct{})
+	for _, h := range heads {
+		// Fail if an advertisement is missing, since every advertisement beyond it would be swept.
+		err := e.walkCompleteChain(ctx, h, func(c cid.Cid, _ *schema.Advertisement) (bool, error) {
+			// Chains are hash-linked; if this one is marked so are the rest.
+			if _, ok := live[c]; ok {
+				return false, nil
+			}
+			live[c] = struct{}{}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+// forEachBlockKey calls fn for every IPLD block stored via the engine link system, i.e. every
+// top-level datastore key that is a CID.
+func (e *Engine) forEachBlockKey(ctx context.Context, fn func(datastore.Key, cid.Cid)) error {
+	results, err := e.ds.Query(ctx, query.Query{KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if r.Error != nil {
+			return r.Error
+		}
+		k := datastore.RawKey(r.Key)
+		if len(k.Namespaces()) != 1 {
+			continue
+		}
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			continue
+		}
+		fn(k, c)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// defaultProviderKey records the ID of the default provider to which the context ID mappings
+// stored without an explicit provider belong. See: Engine.keyToCidKey.
+const defaultProviderKey = "map/defaultProvider"
+
+var dsDefaultProviderKey = datastore.NewKey(defaultProviderKey)
+
+// handOver checks whether the default provider changed since the engine was last started, e.g.
+// because the identity of the provider was rotated, and if so hands the datastore over to the new
+// default provider.
+//
+// The context ID mappings of the previous default provider are kept attributed to it, so that its
+// content can still be updated or removed by passing its ID explicitly, e.g. to
+// Engine.NotifyRemove. A hand-over advertisement is then published on top of the existing chain:
+// it has no entries and carries the ID and addresses of the new default provider, so that indexers
+// learn about the new provider while continuing to sync the chain. Advertisements carry no link
+// between provider identities; content advertised under the previous identity remains attributed
+// to it by indexers.
+//
+// This function returns the CID of the hand-over advertisement, or cid.Undef if none was
+// published. The caller is responsible for announcing it.
+func (e *Engine) handOver(ctx context.Context) (cid.Cid, error) {
+	val, err := e.ds.Get(ctx, dsDefaultProviderKey)
+	if err == datastore.ErrNotFound {
+		// Either a new datastore, or one written by an earlier version of the engine, in which case
+		// the mappings are assumed to belong to the configured default provider.
+		return cid.Undef, e.ds.Put(ctx, dsDefaultProviderKey, []byte(e.provider.ID))
+	}
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get previous default provider: %w", err)
+	}
+	prev, err := peer.IDFromBytes(val)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not decode previous default provider: %w", err)
+	}
+	if prev == e.provider.ID {
+		return cid.Undef, nil
+	}
+	log := log.With("providerID", e.provider.ID, "previousID", prev)
+	log.Warn("Default provider changed; handing over to the new default provider")
+
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+	e.chainLk.Lock()
+	defer e.chainLk.Unlock()
+
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	// Read the addresses of the previous default provider before the hand-over advertisement
+	// becomes the latest, since they may only be recorded by the latest advertisement.
+	prevAddrs, err := e.lastAdvertisedAddrs(ctx, prev)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if prevAddrs != nil {
+		if err = e.putProviderAddrsMap(ctx, b, prev, prevAddrs); err != nil {
+			return cid.Undef, fmt.Errorf("failed to write provider to addresses mapping: %w", err)
+		}
+	}
+	moved, err := e.reassignDefaultMappings(ctx, b, prev)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not reassign mappings of previous default provider: %w", err)
+	}
+
+	_, latestAd, err := e.GetLatestAdv(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	var adv *schema.Advertisement
+	var c cid.Cid
+	if latestAd != nil {
+		_, addrs := e.resolveProvider(nil)
+		stringAddrs := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			stringAddrs = append(stringAddrs, addr.String())
+		}
+		mdBytes := latestAd.Metadata
+		if latestAd.IsRm {
+			// Removal advertisements carry an empty metadata; use a valid empty one likewise.
+			md := metadata.New(metadata.Bitswap{})
+			if mdBytes, err = md.MarshalBinary(); err != nil {
+				return cid.Undef, err
+			}
+		}
+		adv = &schema.Advertisement{
+			Provider:  e.provider.ID.String(),
+			Addresses: stringAddrs,
+			Entries:   schema.NoEntries,
+			Metadata:  mdBytes,
+		}
+		if err = e.linkAndSign(ctx, adv); err != nil {
+			return cid.Undef, err
+		}
+		if err = e.putProviderAddrsMap(ctx, b, e.provider.ID, stringAddrs); err != nil {
+			return cid.Undef, fmt.Errorf("failed to write provider to addresses mapping: %w", err)
+		}
+		if c, err = e.publishLocal(ctx, b, *adv); err != nil {
+			return cid.Undef, fmt.Errorf("failed to publish hand-over advertisement locally: %w", err)
+		}
+	}
+	if err = b.Put(ctx, dsDefaultProviderKey, []byte(e.provider.ID)); err != nil {
+		return cid.Undef, err
+	}
+	if err = b.Commit(ctx); err != nil {
+		return cid.Undef, fmt.Errorf("could not commit hand-over to new default provider: %w", err)
+	}
+	if adv != nil {
+		e.adStored(c, *adv)
+	}
+	log.Infow("Handed over to new default provider", "contextIDs", moved, "adCid", c)
+	return c, nil
+}
+
+// reassignDefaultMappings writes to w the move of the context ID mappings stored without an
+// explicit provider to the given previous default provider, and returns the number of context IDs
+// moved.
+func (e *Engine) reassignDefaultMappings(ctx context.Context, w datastore.Write, prev peer.ID) (int, error) {
+	var moved int
+	err := e.forEachKeyCidMapping(ctx, func(p peer.ID, contextID []byte, c cid.Cid) error {
+		// Mappings without an explicit provider are now resolved to the new default provider.
+		if p != e.provider.ID {
+			return nil
+		}
+		log.Debugw("Reassigning context ID to previous default provider", "previousID", prev,
+			"contextID", base64.StdEncoding.EncodeToString(contextID))
+		md, err := e.ds.Get(ctx, e.keyToMetadataKey(p, contextID))
+		switch err {
+		case nil:
+			if err = w.Put(ctx, e.keyToMetadataKey(prev, contextID), md); err != nil {
+				return err
+			}
+			if err = e.deleteKeyMetadataMap(ctx, w, p, contextID); err != nil {
+				return err
+			}
+		case datastore.ErrNotFound:
+		default:
+			return err
+		}
+		if err = e.deleteKeyCidMap(ctx, w, p, contextID); err != nil {
+			return err
+		}
+		// Drop the legacy reverse mapping, if any, since it implies the default provider.
+		if err = w.Delete(ctx, e.cidToKeyKey(c)); err != nil {
+			return err
+		}
+		moved++
+		return e.putKeyCidMap(ctx, w, prev, contextID, c)
+	})
+	return moved, err
+}
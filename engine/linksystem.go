@@ -101,7 +101,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			if err != nil {
 				return nil, err
 			}
-			mhIter, err := e.mhLister(ctx, provider, key.ContextID)
+			mhIter, err := e.listMultihashes(ctx, provider, key.ContextID)
 			if err != nil {
 				return nil, err
 			}
@@ -109,11 +109,12 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			// Store the linked list entries in cache as we generate them.  We
 			// use the cache linksystem that stores entries in an in-memory
 			// datastore.
-			_, err = e.entriesChunker.Chunk(ctx, mhIter)
+			root, err := e.entriesChunker.Chunk(ctx, mhIter)
 			if err != nil {
 				log.Errorf("Error generating linked list from multihash lister: %s", err)
 				return nil, err
 			}
+			e.emit(EntriesChunkedEvent{Root: root.(cidlink.Link).Cid, Provider: provider, ContextID: key.ContextID})
 		} else {
 			log.Debugw("Found cache entry for CID", "cid", c)
 		}
@@ -3,6 +3,7 @@ package engine
 import (
 	"fmt"
 	"net/url"
+	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/index-provider/engine/chunker"
@@ -49,11 +50,26 @@ type (
 		// announce messages to.
 		announceURLs []*url.URL
 
+		// announceWindow and announceCount configure the coalescing of announcements; see
+		// WithAnnounceCoalescing. Coalescing is disabled if both are zero.
+		announceWindow time.Duration
+		announceCount  int
+
+		compactionGracePeriod time.Duration
+
+		// announceRetryInitialBackoff, announceRetryMaxBackoff and announceRetryMaxAge configure
+		// the retry of failed direct HTTP announcements; see WithAnnounceRetry.
+		announceRetryInitialBackoff time.Duration
+		announceRetryMaxBackoff     time.Duration
+		announceRetryMaxAge         time.Duration
+
 		// key is always initialized from the host peerstore.
 		// Setting an explicit identity must not be exposed unless it is tightly coupled with the
 		// host identity. Otherwise, the signature of advertisement will not match the libp2p host
 		// ID.
 		key crypto.PrivKey
+		// signer signs advertisements; see WithSigner. Defaults to signing with key.
+		signer Signer
 
 		// It's important to not to change this parameter when running against existing datastores. The reason for that is to maintain backward compatibility.
 		// Older records from previous library versions aren't indexed by provider ID as there could have been only one provider in the previous versions.
@@ -68,9 +84,17 @@ type (
 		pubExtraGossipData []byte
 
 		entCacheCap int
+		entCacheDs  datastore.Batching
 		purgeCache  bool
 		chunker     chunker.NewChunkerFunc
 
+		// consistencyCheck sets whether to check for half-applied mappings on start; see
+		// WithConsistencyCheck.
+		consistencyCheck bool
+		// consistencyRepair sets whether to repair the half-applied mappings found by the
+		// consistency check; see WithConsistencyRepair.
+		consistencyRepair bool
+
 		syncPolicy *policy.Policy
 	}
 )
@@ -87,6 +111,13 @@ func newOptions(o ...Option) (*options, error) {
 		// 16384 multihashes per chunk.
 		chunker:    chunker.NewChainChunkerFunc(16384),
 		purgeCache: false,
+		// Report any mappings left half-applied by a crash on start, without repairing them.
+		// Keep advertisements replaced by compaction for a day.
+		compactionGracePeriod: 24 * time.Hour,
+		// Retry failed direct HTTP announcements for up to a day, at most every half hour.
+		announceRetryInitialBackoff: 30 * time.Second,
+		announceRetryMaxBackoff:     30 * time.Minute,
+		announceRetryMaxAge:         24 * time.Hour,
 	}
 
 	for _, apply := range o {
@@ -118,11 +149,20 @@ func newOptions(o ...Option) (*options, error) {
 
 	// Initialize private key from libp2p host
 	opts.key = opts.h.Peerstore().PrivKey(opts.h.ID())
-	// Defensively check that host's self private key is indeed set.
-	if opts.key == nil {
+	// Defensively check that host's self private key is indeed set whenever it is needed, i.e. to
+	// sign advertisements if no signer is given, or to sign the head published over HTTP.
+	if opts.key == nil && (opts.signer == nil || opts.pubKind == HttpPublisher) {
 		return nil, fmt.Errorf("cannot find private key in self peerstore; libp2p host is misconfigured")
 	}
 
+	if opts.signer == nil {
+		signer, err := NewKeySigner(opts.key)
+		if err != nil {
+			return nil, err
+		}
+		opts.signer = signer
+	}
+
 	if len(opts.provider.Addrs) == 0 {
 		opts.provider.Addrs = opts.h.Addrs()
 		log.Infow("Retrieval address not configured; using host listen addresses instead.", "retrievalAddrs", opts.provider.Addrs)
@@ -154,6 +194,38 @@ func WithPurgeCacheOnStart(p bool) Option {
 	}
 }
 
+// WithConsistencyCheck sets whether to check the consistency of the internal advertisement
+// mappings against the advertisement chain when the provider engine starts, and report the
+// mappings left half-applied by a crash part way through publishing an advertisement. It is
+// skipped if the chain cannot be walked back to its first advertisement.
+//
+// The check reads every context ID mapping and walks the entire advertisement chain, and so delays
+// the start of the engine by time proportional to the number of context IDs and the length of the
+// chain. Consider enabling it only after an unclean shutdown, or compacting long chains first;
+// see Engine.Compact.
+//
+// If unset, the consistency check is disabled. To repair the mappings found, see
+// WithConsistencyRepair.
+func WithConsistencyCheck(c bool) Option {
+	return func(o *options) error {
+		o.consistencyCheck = c
+		return nil
+	}
+}
+
+// WithConsistencyRepair sets whether to repair the half-applied mappings found by the consistency
+// check, rather than only reporting them. Repairing deletes the mappings of context IDs whose
+// advertisement was never stored. Repairing implies the consistency check; see
+// WithConsistencyCheck.
+//
+// If unset, mappings are only reported.
+func WithConsistencyRepair(r bool) Option {
+	return func(o *options) error {
+		o.consistencyRepair = r
+		return nil
+	}
+}
+
 // WithChainedEntries sets format of advertisement entries to chained Entry Chunk with the
 // given chunkSize as the maximum number of multihashes per chunk.
 //
@@ -209,6 +281,23 @@ func WithEntriesCacheCapacity(s int) Option {
 	}
 }
 
+// WithEntriesCacheDatastore sets the datastore in which the cached advertisement entries DAGs are
+// stored. This allows the cache to be stored separately from advertisements and internal mappings,
+// e.g. on a different disk or datastore backend, such that cache churn does not affect them.
+//
+// If unset, the cache is stored in the datastore set via WithDatastore under the "/cache/links"
+// namespace.
+//
+// Note that changing the cache datastore of an existing engine does not remove the entries cached
+// in the previous datastore.
+// See: WithEntriesCacheCapacity, WithPurgeCacheOnStart.
+func WithEntriesCacheDatastore(ds datastore.Batching) Option {
+	return func(o *options) error {
+		o.entCacheDs = ds
+		return nil
+	}
+}
+
 // WithPublisherKind sets the kind of publisher used to announce new advertisements.
 // If unset, advertisements are only stored locally and no announcements are made.
 // See: PublisherKind.
@@ -299,6 +388,10 @@ func WithRetrievalAddrs(addr ...multiaddr.Multiaddr) Option {
 	}
 }
 
+// WithSyncPolicy sets the policy that determines which peers are allowed to sync advertisements
+// and entries from the engine. If unset, all peers are allowed.
+//
+// See: Engine.SyncPolicy.
 func WithSyncPolicy(syncPolicy *policy.Policy) Option {
 	return func(o *options) error {
 		o.syncPolicy = syncPolicy
@@ -306,6 +399,23 @@ func WithSyncPolicy(syncPolicy *policy.Policy) Option {
 	}
 }
 
+// WithSigner sets the signer used to sign published advertisements, e.g. to keep the signing keys
+// in a separate signing service, or to sign the advertisements of a previous provider identity
+// with its key.
+//
+// The signer only signs advertisements. The private key of the host must still be available
+// locally to publish via HttpPublisher, since the head of the chain published over HTTP is signed
+// using it; other publishers do not require it when a signer is set.
+//
+// If unset, advertisements are signed using the private key of the host.
+// See: NewKeySigner.
+func WithSigner(s Signer) Option {
+	return func(o *options) error {
+		o.signer = s
+		return nil
+	}
+}
+
 // WithProvider sets the peer and addresses for the provider to put in indexing advertisements.
 // This value overrides `WithRetrievalAddrs`
 func WithProvider(provider peer.AddrInfo) Option {
@@ -342,3 +452,74 @@ func WithDirectAnnounce(announceURLs ...string) Option {
 		return nil
 	}
 }
+
+// WithAnnounceCoalescing delays the announcement of newly published advertisements such that only
+// the latest head of the advertisement chain is announced once, either after the given window has
+// elapsed since the first unannounced advertisement, or once the given count of unannounced
+// advertisements is reached; whichever happens first. A zero window or count disables the
+// corresponding trigger.
+//
+// This reduces the number of gossipsub messages and direct HTTP announcements sent to indexers
+// when advertisements are published in bursts. Any unannounced advertisements are announced when
+// the engine is shut down.
+//
+// If unset, or both window and count are zero, every published advertisement is announced
+// immediately.
+// See: WithDirectAnnounce, Engine.PendingAnnounces.
+func WithAnnounceCoalescing(window time.Duration, count int) Option {
+	return func(o *options) error {
+		if window < 0 {
+			return fmt.Errorf("announce coalescing window cannot be negative: %s", window)
+		}
+		if count < 0 {
+			return fmt.Errorf("announce coalescing count cannot be negative: %d", count)
+		}
+		o.announceWindow = window
+		o.announceCount = count
+		return nil
+	}
+}
+
+// WithCompactionGracePeriod sets the duration for which the advertisements replaced by
+// Engine.Compact remain readable, e.g. by indexers that are part way through syncing the replaced
+// chain. Once elapsed, the replaced advertisements are deleted by subsequent compactions.
+//
+// If unset, the grace period of 24 hours is used.
+func WithCompactionGracePeriod(d time.Duration) Option {
+	return func(o *options) error {
+		if d < 0 {
+			return fmt.Errorf("compaction grace period cannot be negative: %s", d)
+		}
+		o.compactionGracePeriod = d
+		return nil
+	}
+}
+
+// WithAnnounceRetry configures the retry of direct HTTP announcements that fail, e.g. because an
+// indexer is down. Failed announcements are queued in the datastore, and retried with exponential
+// backoff starting from initialBackoff up to maxBackoff. An announcement that keeps failing is
+// dropped once maxAge has elapsed since it first failed. A zero maxAge disables retries, in which
+// case failed announcements are only logged.
+//
+// If unset, failed announcements are retried with backoff starting from 30 seconds up to 30
+// minutes, for a maximum of 24 hours.
+// See: WithDirectAnnounce, Engine.AnnounceRetries.
+func WithAnnounceRetry(initialBackoff, maxBackoff, maxAge time.Duration) Option {
+	return func(o *options) error {
+		if maxAge < 0 {
+			return fmt.Errorf("announce retry max age cannot be negative: %s", maxAge)
+		}
+		if maxAge > 0 {
+			if initialBackoff <= 0 {
+				return fmt.Errorf("announce retry initial backoff must be positive: %s", initialBackoff)
+			}
+			if maxBackoff < initialBackoff {
+				return fmt.Errorf("announce retry max backoff %s cannot be less than initial backoff %s", maxBackoff, initialBackoff)
+			}
+		}
+		o.announceRetryInitialBackoff = initialBackoff
+		o.announceRetryMaxBackoff = maxBackoff
+		o.announceRetryMaxAge = maxAge
+		return nil
+	}
+}
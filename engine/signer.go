@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// Signer signs the advertisements published by the engine. Implementations may hold the signing
+// keys outside of the engine, e.g. in a separate signing service.
+//
+// See: WithSigner, NewKeySigner.
+type Signer interface {
+	// Sign signs the given advertisement in place, i.e. by setting its signature.
+	Sign(ctx context.Context, ad *schema.Advertisement) error
+}
+
+// KeySigner is a Signer that signs advertisements using private keys held in memory.
+type KeySigner struct {
+	key      crypto.PrivKey
+	previous map[peer.ID]crypto.PrivKey
+}
+
+// NewKeySigner instantiates a new KeySigner that signs advertisements using the given key.
+//
+// Advertisements whose provider is the peer ID of one of the given previous keys are signed using
+// that key instead. This allows a provider whose identity was rotated to keep updating the content
+// advertised under a previous identity, since indexers only accept advertisements signed by the
+// provider itself or by a publisher allowed to publish on its behalf.
+func NewKeySigner(key crypto.PrivKey, previous ...crypto.PrivKey) (*KeySigner, error) {
+	if key == nil {
+		return nil, fmt.Errorf("signing key must be specified")
+	}
+	s := &KeySigner{
+		key:      key,
+		previous: make(map[peer.ID]crypto.PrivKey, len(previous)),
+	}
+	for _, pk := range previous {
+		id, err := peer.IDFromPrivateKey(pk)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get peer ID of previous key: %w", err)
+		}
+		s.previous[id] = pk
+	}
+	return s, nil
+}
+
+// Sign signs the given advertisement using the previous key of its provider if any, or the key of
+// the signer otherwise.
+func (s *KeySigner) Sign(_ context.Context, ad *schema.Advertisement) error {
+	key := s.key
+	if p, err := peer.Decode(ad.Provider); err == nil {
+		if pk, ok := s.previous[p]; ok {
+			key = pk
+		}
+	}
+	return ad.Sign(key)
+}
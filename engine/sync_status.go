@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ErrSyncDenied signals that a sync was denied by the sync policy. See: WithSyncPolicy.
+var ErrSyncDenied = errors.New("sync denied by policy")
+
+// SyncStatus captures the advertisements and entries synced by an indexer from the engine.
+type SyncStatus struct {
+	// Peer is the ID of the indexer. It is empty for syncs over HTTP, which are anonymous; all such
+	// syncs are recorded as a single SyncStatus.
+	Peer peer.ID
+	// LastAdCid is the CID of the latest advertisement synced by the indexer, or cid.Undef if the
+	// indexer has only synced entries.
+	LastAdCid cid.Cid
+	// LastAdSyncedAt is the time at which LastAdCid was synced.
+	LastAdSyncedAt time.Time
+	// EntriesBlocksServed is the number of entries blocks served to the indexer.
+	EntriesBlocksServed int64
+	// Lag is the number of advertisements published after LastAdCid, or -1 if LastAdCid is not in
+	// the current advertisement chain.
+	Lag int
+}
+
+// syncTracker records the sync activity of indexers in memory.
+type syncTracker struct {
+	lock     sync.Mutex
+	statuses map[peer.ID]*SyncStatus
+}
+
+func (t *syncTracker) status(p peer.ID) *SyncStatus {
+	if t.statuses == nil {
+		t.statuses = make(map[peer.ID]*SyncStatus)
+	}
+	s, ok := t.statuses[p]
+	if !ok {
+		s = &SyncStatus{Peer: p}
+		t.statuses[p] = s
+	}
+	return s
+}
+
+func (t *syncTracker) recordAdSynced(p peer.ID, c cid.Cid) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := t.status(p)
+	s.LastAdCid = c
+	s.LastAdSyncedAt = time.Now()
+}
+
+func (t *syncTracker) recordEntriesServed(p peer.ID, n int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status(p).EntriesBlocksServed += n
+}
+
+func (t *syncTracker) list() []SyncStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	statuses := make([]SyncStatus, 0, len(t.statuses))
+	for _, s := range t.statuses {
+		statuses = append(statuses, *s)
+	}
+	return statuses
+}
+
+// SyncStatus returns the sync status of every indexer that has synced from the engine since it was
+// started, sorted by peer ID.
+//
+// Syncs via the data transfer publisher are only tracked if the engine is instantiated with an
+// existing data transfer manager; see WithDataTransfer. Syncs via the HTTP publisher are tracked
+// anonymously, and an advertisement is only recorded as synced over HTTP if it was the latest
+// advertisement when served.
+func (e *Engine) SyncStatus(ctx context.Context) ([]SyncStatus, error) {
+	statuses := e.syncs.list()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Peer < statuses[j].Peer })
+	if len(statuses) == 0 {
+		return statuses, nil
+	}
+
+	// Index the position of advertisements in chain in order to calculate the lag of each indexer.
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[cid.Cid]struct{})
+	for _, s := range statuses {
+		if s.LastAdCid != cid.Undef {
+			pending[s.LastAdCid] = struct{}{}
+		}
+	}
+	lags := make(map[cid.Cid]int)
+	var depth int
+	err = e.walkChain(ctx, head, func(c cid.Cid, _ *schema.Advertisement) (bool, error) {
+		if _, ok := pending[c]; ok {
+			lags[c] = depth
+			delete(pending, c)
+		}
+		depth++
+		return len(pending) != 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		lag, ok := lags[statuses[i].LastAdCid]
+		if !ok {
+			lag = -1
+		}
+		statuses[i].Lag = lag
+	}
+	return statuses, nil
+}
+
+// trackSyncedDAG records the completion of a sync by the given peer of the DAG with the given
+// root, where n is the number of blocks sent.
+func (e *Engine) trackSyncedDAG(ctx context.Context, p peer.ID, root cid.Cid, n int64) {
+	isAd, err := e.ds.Has(ctx, datastore.NewKey(root.String()))
+	if err != nil {
+		log.Warnw("Failed to check if synced DAG is advertisement", "root", root, "err", err)
+		return
+	}
+	if isAd {
+		e.syncs.recordAdSynced(p, root)
+	} else {
+		e.syncs.recordEntriesServed(p, n)
+	}
+}
+
+// httpLinkSystem returns a copy of the engine link system that enforces the sync policy on, and
+// records the blocks served by, the HTTP publisher.
+//
+// Syncs over HTTP are anonymous; they are evaluated against the sync policy as the empty peer ID,
+// i.e. they are allowed only if the policy allows peers by default.
+func (e *Engine) httpLinkSystem() ipld.LinkSystem {
+	lsys := e.lsys
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		if !e.syncPolicy.Allowed("") {
+			log.Debugw("Denied anonymous http sync by policy", "cid", lnk)
+			return nil, ErrSyncDenied
+		}
+		r, err := e.lsys.StorageReadOpener(lctx, lnk)
+		if err != nil {
+			return nil, err
+		}
+		// The HTTP publisher does not set a context.
+		ctx := lctx.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		c := lnk.(cidlink.Link).Cid
+		isAd, err := e.ds.Has(ctx, datastore.NewKey(c.String()))
+		if err != nil {
+			log.Warnw("Failed to check if served block is advertisement", "cid", c, "err", err)
+			return r, nil
+		}
+		if !isAd {
+			e.syncs.recordEntriesServed("", 1)
+			return r, nil
+		}
+		// Indexers walk the chain backwards from the latest advertisement; only record the latest
+		// to avoid walking the chain on every read.
+		latest, err := e.getLatestAdCid(ctx)
+		if err == nil && latest == c {
+			e.syncs.recordAdSynced("", c)
+		}
+		return r, nil
+	}
+	return lsys
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+type (
+	// ChainReport captures the outcome of verifying the advertisement chain.
+	//
+	// See: Engine.VerifyChain.
+	ChainReport struct {
+		// Head is the CID of the latest advertisement from which the chain was verified, or
+		// cid.Undef if no advertisements are published.
+		Head cid.Cid
+		// Advertisements is the number of advertisements verified.
+		Advertisements int
+		// Mappings is the number of context ID to entries mappings verified.
+		Mappings int
+		// Problems lists the problems found, if any.
+		Problems []ChainProblem
+	}
+
+	// ChainProblem describes a problem found while verifying the advertisement chain.
+	ChainProblem struct {
+		// AdCid is the CID of the advertisement the problem was found at, or cid.Undef if the
+		// problem is with a context ID mapping.
+		AdCid cid.Cid
+		// Provider is the ID of the provider the problem relates to, if known.
+		Provider peer.ID
+		// ContextID is the context ID the problem relates to, if any.
+		ContextID []byte
+		// Err describes the problem.
+		Err error
+	}
+)
+
+// OK checks whether no problems were found.
+func (r *ChainReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// mappedContext captures a context ID mapped to an entries CID, and whether it is referenced by an
+// advertisement in the chain.
+type mappedContext struct {
+	provider   peer.ID
+	contextID  []byte
+	referenced bool
+}
+
+// VerifyChain checks the integrity of the advertisement chain, e.g. after an unclean shutdown or
+// a disk failure. The chain is walked from the latest advertisement through the previous links,
+// checking that:
+//  - every advertisement exists, matches its CID, is valid and carries a valid signature;
+//  - the entries of every advertisement that is currently mapped to a context ID resolve via the
+//    engine's link system;
+//  - every context ID to entries mapping is referenced by an advertisement that is not a removal.
+//
+// The walk stops at the first advertisement that cannot be loaded, since the rest of the chain is
+// unreachable. Problems are recorded in the returned report; an error is only returned if the
+// verification itself could not be carried out.
+//
+// The entries of context IDs whose multihashes are no longer cached are regenerated via the
+// registered multihash lister, and so verification may take a while for large providers.
+func (e *Engine) VerifyChain(ctx context.Context) (*ChainReport, error) {
+	e.gcLk.RLock()
+	defer e.gcLk.RUnlock()
+
+	// Read the mappings before the latest advertisement, so that any advertisement published
+	// concurrently is not reported as missing; mappings are stored along with their advertisement.
+	var mappings []*mappedContext
+	// Mappings are matched to advertisements by entries CID and context ID only, since mappings
+	// recorded by earlier versions of the engine may not identify the provider.
+	mapped := make(map[string][]*mappedContext)
+	var report ChainReport
+	err := e.forEachKeyCidMapping(ctx, func(p peer.ID, contextID []byte, c cid.Cid) error {
+		mc := &mappedContext{provider: p, contextID: contextID}
+		mappings = append(mappings, mc)
+		key := string(c.Bytes()) + string(contextID)
+		mapped[key] = append(mapped[key], mc)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list context ID mappings: %w", err)
+	}
+
+	report.Head, err = e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	log := log.With("head", report.Head)
+	log.Info("Verifying advertisement chain")
+
+	lsys := e.vanillaLinkSystem()
+	visited := make(map[cid.Cid]struct{})
+	resolved := make(map[cid.Cid]struct{})
+	for c := report.Head; c != cid.Undef; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := visited[c]; ok {
+			report.addProblem(c, "", nil, errors.New("advertisement chain contains a cycle"))
+			break
+		}
+		visited[c] = struct{}{}
+
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				err = errors.New("advertisement not found")
+			}
+			report.addProblem(c, "", nil, fmt.Errorf("cannot load advertisement: %w", err))
+			break
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			report.addProblem(c, "", nil, fmt.Errorf("cannot decode advertisement: %w", err))
+			break
+		}
+		report.Advertisements++
+		p, _ := peer.Decode(ad.Provider)
+
+		if err = ad.Validate(); err != nil {
+			report.addProblem(c, p, ad.ContextID, fmt.Errorf("invalid advertisement: %w", err))
+		}
+		if _, err = ad.VerifySignature(); err != nil {
+			report.addProblem(c, p, ad.ContextID, fmt.Errorf("invalid signature: %w", err))
+		}
+
+		if !ad.IsRm && ad.Entries != nil && ad.Entries != schema.NoEntries {
+			entries := ad.Entries.(cidlink.Link).Cid
+			if mcs, ok := mapped[string(entries.Bytes())+string(ad.ContextID)]; ok {
+				for _, mc := range mcs {
+					mc.referenced = true
+				}
+				// Only the entries of mapped context IDs are checked, since the multihashes of
+				// superseded or removed context IDs may no longer be available to the lister.
+				if _, ok := resolved[entries]; !ok {
+					resolved[entries] = struct{}{}
+					_, err = e.lsys.Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, basicnode.Prototype.Any)
+					if err != nil {
+						report.addProblem(c, p, ad.ContextID, fmt.Errorf("cannot resolve entries %s: %w", entries, err))
+					}
+				}
+			}
+		}
+
+		if ad.PreviousID == nil {
+			break
+		}
+		c = ad.PreviousID.(cidlink.Link).Cid
+	}
+
+	report.Mappings = len(mappings)
+	for _, mc := range mappings {
+		if !mc.referenced {
+			report.addProblem(cid.Undef, mc.provider, mc.contextID, errors.New("context ID mapping is not referenced by any advertisement"))
+		}
+	}
+
+	log.Infow("Verified advertisement chain", "advertisements", report.Advertisements, "mappings", report.Mappings, "problems", len(report.Problems))
+	return &report, nil
+}
+
+func (r *ChainReport) addProblem(c cid.Cid, p peer.ID, contextID []byte, err error) {
+	log.Warnw("Found problem in advertisement chain", "adCid", c, "providerID", p, "contextID", contextID, "err", err)
+	r.Problems = append(r.Problems, ChainProblem{
+		AdCid:     c,
+		Provider:  p,
+		ContextID: contextID,
+		Err:       err,
+	})
+}
@@ -62,6 +62,19 @@ type Interface interface {
 	// This function returns the ID of the advertisement published.
 	NotifyPut(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error)
 
+	// NotifyPutMany signals the provider that the list of multihashes looked up by each of the
+	// given requests is available. Each request is handled the same way as NotifyPut, except that
+	// the generated advertisements are all appended to the local chain first and only the final
+	// head of the chain is announced, once.
+	//
+	// The returned results correspond to the given requests by index. Failure to advertise one
+	// request, e.g. ErrAlreadyAdvertised, is reported in its corresponding result and does not
+	// abort the remaining requests. The returned error is non-nil only if announcing the final
+	// head fails.
+	//
+	// See: NotifyPut.
+	NotifyPutMany(ctx context.Context, reqs []NotifyPutRequest) ([]NotifyPutResult, error)
+
 	// NotifyRemove signals to the provider that the multihashes that
 	// corresponded to the given provider and contextID are no longer available.  An advertisement
 	// is then generated, appended to the chain of advertisements and published
@@ -87,6 +100,28 @@ type Interface interface {
 	Shutdown() error
 }
 
+// NotifyPutRequest represents a single (provider, contextID, metadata) tuple to advertise via
+// Interface.NotifyPutMany.
+//
+// See: Interface.NotifyPut.
+type NotifyPutRequest struct {
+	// Provider is the provider to advertise for. If nil, the default configured provider is
+	// assumed.
+	Provider *peer.AddrInfo
+	// ContextID is the context ID by which the list of multihashes is looked up.
+	ContextID []byte
+	// Metadata is the retrieval metadata to advertise.
+	Metadata metadata.Metadata
+}
+
+// NotifyPutResult is the outcome of advertising a single NotifyPutRequest.
+type NotifyPutResult struct {
+	// AdCid is the CID of the generated advertisement, or cid.Undef if Err is non-nil.
+	AdCid cid.Cid
+	// Err is the error that occurred while advertising the request, if any.
+	Err error
+}
+
 // MultihashIterator iterates over a list of multihashes.
 //
 // See: CarMultihashIterator.
@@ -0,0 +1,178 @@
+// Package metrics defines the Prometheus metrics exposed by the index provider, and the HTTP
+// handler that serves them.
+//
+// Metrics are registered with a dedicated registry, alongside the Go runtime and process
+// collectors, rather than the Prometheus default registry. This avoids collisions with metrics
+// registered by dependencies. See: Handler.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "provider"
+
+// Labels used to partition metrics.
+const (
+	// MethodLabel is the announcement method; one of "pubsub" or "http".
+	MethodLabel = "method"
+	// URLLabel is the URL of the indexer an announcement is sent to, or empty for pubsub.
+	URLLabel = "url"
+	// KindLabel is the kind of advertisement published; one of "put" or "remove".
+	KindLabel = "kind"
+	// RouteLabel is the path template of an admin server route.
+	RouteLabel = "route"
+	// CodeLabel is the HTTP status code of an admin server response.
+	CodeLabel = "code"
+)
+
+var (
+	// AdsPublished counts the advertisements stored by the engine, partitioned by KindLabel.
+	AdsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "engine",
+		Name:      "ads_published_total",
+		Help:      "Number of advertisements published.",
+	}, []string{KindLabel})
+	// AnnounceLatency observes the time taken to announce an advertisement, partitioned by
+	// MethodLabel and URLLabel.
+	AnnounceLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "engine",
+		Name:      "announce_latency_seconds",
+		Help:      "Latency of advertisement announcements.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{MethodLabel, URLLabel})
+	// AnnounceFailures counts the advertisement announcements that failed, partitioned by
+	// MethodLabel and URLLabel.
+	AnnounceFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "engine",
+		Name:      "announce_failures_total",
+		Help:      "Number of failed advertisement announcements.",
+	}, []string{MethodLabel, URLLabel})
+	// ListerLatency observes the time taken by the registered multihash lister to list the
+	// multihashes of a context ID.
+	ListerLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "engine",
+		Name:      "multihash_lister_latency_seconds",
+		Help:      "Latency of listing the multihashes of a context ID.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// CacheHits counts the entries chunks found in the entries cache.
+	CacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "cache_hits_total",
+		Help:      "Number of entries chunks found in cache.",
+	})
+	// CacheMisses counts the entries chunks not found in the entries cache.
+	CacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "cache_misses_total",
+		Help:      "Number of entries chunks not found in cache.",
+	})
+	// CacheEvictions counts the entries DAGs evicted from the entries cache.
+	CacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries DAGs evicted from cache.",
+	})
+	// CacheLen is the number of entries DAGs in the entries cache.
+	CacheLen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "cache_len",
+		Help:      "Number of entries DAGs in cache.",
+	})
+	// CacheCap is the maximum number of entries DAGs in the entries cache.
+	CacheCap = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "cache_cap",
+		Help:      "Maximum number of entries DAGs in cache.",
+	})
+	// ChunkingDuration observes the time taken to chunk the multihashes of a context ID into an
+	// entries DAG.
+	ChunkingDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "chunker",
+		Name:      "chunking_duration_seconds",
+		Help:      "Duration of chunking multihashes into an entries DAG.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// AdsMirrored counts the advertisements mirrored successfully.
+	AdsMirrored = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mirror",
+		Name:      "ads_mirrored_total",
+		Help:      "Number of advertisements mirrored.",
+	})
+	// MirrorFailures counts the advertisements that failed to mirror.
+	MirrorFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mirror",
+		Name:      "mirror_failures_total",
+		Help:      "Number of advertisements that failed to mirror.",
+	})
+	// MirrorSyncLag is the number of advertisements synced from the source that are yet to be
+	// mirrored.
+	MirrorSyncLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mirror",
+		Name:      "sync_lag_ads",
+		Help:      "Number of advertisements synced from the source that are yet to be mirrored.",
+	})
+
+	// AdminRequests counts the requests handled by the admin server, partitioned by RouteLabel and
+	// CodeLabel.
+	AdminRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "admin",
+		Name:      "requests_total",
+		Help:      "Number of requests handled by the admin server.",
+	}, []string{RouteLabel, CodeLabel})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		AdsPublished,
+		AnnounceLatency,
+		AnnounceFailures,
+		ListerLatency,
+		CacheHits,
+		CacheMisses,
+		CacheEvictions,
+		CacheLen,
+		CacheCap,
+		ChunkingDuration,
+		AdsMirrored,
+		MirrorFailures,
+		MirrorSyncLag,
+		AdminRequests,
+	)
+}
+
+// Handler returns an HTTP handler that serves the metrics in Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Since returns the number of seconds elapsed since the given time, for use with histograms.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
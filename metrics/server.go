@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("provider/metrics")
+
+// Server serves the metrics over HTTP at path /metrics.
+type Server struct {
+	server *http.Server
+	l      net.Listener
+}
+
+// NewServer instantiates a new metrics server that listens on the given address.
+func NewServer(listenAddr string) (*Server, error) {
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &Server{
+		server: &http.Server{Handler: mux},
+		l:      l,
+	}, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+// Start serves the metrics, blocking until the server is shut down.
+func (s *Server) Start() error {
+	log.Infow("metrics http server listening", "addr", s.l.Addr())
+	return s.server.Serve(s.l)
+}
+
+// Shutdown gracefully shuts down the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info("metrics http server shutdown")
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,29 @@
+package metrics_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/filecoin-project/index-provider/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ServesMetrics(t *testing.T) {
+	s, err := metrics.NewServer("127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.Start() }()
+	t.Cleanup(func() { require.NoError(t, s.Shutdown(context.Background())) })
+
+	metrics.AdsPublished.WithLabelValues("put").Inc()
+
+	resp, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `provider_engine_ads_published_total{kind="put"}`)
+	require.Contains(t, string(body), "go_goroutines")
+}
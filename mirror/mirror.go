@@ -14,6 +14,7 @@ import (
 	"github.com/filecoin-project/go-legs"
 	"github.com/filecoin-project/go-legs/dtsync"
 	"github.com/filecoin-project/index-provider/engine/chunker"
+	"github.com/filecoin-project/index-provider/metrics"
 	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
@@ -163,12 +164,17 @@ func (m *Mirror) Start() error {
 				continue
 			}
 
+			metrics.MirrorSyncLag.Set(float64(len(syncedAdCids)))
 			for _, adCid := range syncedAdCids {
 				err := m.mirror(ctx, adCid)
 				if err != nil {
 					log.Errorw("Failed to mirror ad", "cid", adCid, "err", err)
+					metrics.MirrorFailures.Inc()
 					// TODO add an option on what to do if the mirroring of an ad failed?
+				} else {
+					metrics.AdsMirrored.Inc()
 				}
+				metrics.MirrorSyncLag.Dec()
 			}
 
 			syncedCount := len(syncedAdCids)
@@ -86,6 +86,21 @@ func (mr *MockInterfaceMockRecorder) NotifyPut(ctx, provider, contextID, md inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyPut", reflect.TypeOf((*MockInterface)(nil).NotifyPut), ctx, provider, contextID, md)
 }
 
+// NotifyPutMany mocks base method.
+func (m *MockInterface) NotifyPutMany(ctx context.Context, reqs []provider.NotifyPutRequest) ([]provider.NotifyPutResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyPutMany", ctx, reqs)
+	ret0, _ := ret[0].([]provider.NotifyPutResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NotifyPutMany indicates an expected call of NotifyPutMany.
+func (mr *MockInterfaceMockRecorder) NotifyPutMany(ctx, reqs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyPutMany", reflect.TypeOf((*MockInterface)(nil).NotifyPutMany), ctx, reqs)
+}
+
 // NotifyRemove mocks base method.
 func (m *MockInterface) NotifyRemove(ctx context.Context, providerID peer.ID, contextID []byte) (cid.Cid, error) {
 	m.ctrl.T.Helper()
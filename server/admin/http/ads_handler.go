@@ -0,0 +1,170 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/storetheindex/api/v0/ingest/schema"
+	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+const (
+	// defaultListAdvsLimit is the number of advertisements listed per page when no limit is
+	// specified.
+	defaultListAdvsLimit = 10
+	// maxListAdvsLimit is the maximum number of advertisements listed per page.
+	maxListAdvsLimit = 1000
+)
+
+func (s *Server) getLatestAdvHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received get latest advertisement request")
+
+	adCid, ad, err := s.e.GetLatestAdv(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to get latest advertisement: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if adCid == cid.Undef {
+		msg := "no advertisements published"
+		log.Info(msg)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	log.Infow("Retrieved latest advertisement successfully", "cid", adCid)
+	resp := toAdvRes(adCid, ad)
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) getAdvHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received get advertisement request")
+
+	adCid, err := cid.Decode(mux.Vars(r)["cid"])
+	if err != nil {
+		msg := fmt.Sprintf("invalid advertisement cid: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	ad, err := s.e.GetAdv(r.Context(), adCid)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			msg := fmt.Sprintf("advertisement not found: %s", adCid)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to get advertisement: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Retrieved advertisement successfully", "cid", adCid)
+	resp := toAdvRes(adCid, ad)
+	respond(w, http.StatusOK, resp)
+}
+
+// listAdvsHandler walks the advertisement chain from the advertisement specified by the optional
+// query parameter "from", or the latest advertisement if unspecified, listing at most as many
+// advertisements as the optional query parameter "limit".
+func (s *Server) listAdvsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received list advertisements request")
+
+	query := r.URL.Query()
+	limit := defaultListAdvsLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListAdvsLimit {
+			msg := fmt.Sprintf("limit must be an integer between 1 and %d", maxListAdvsLimit)
+			log.Errorw(msg, "limit", v)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var next cid.Cid
+	if v := query.Get("from"); v != "" {
+		var err error
+		next, err = cid.Decode(v)
+		if err != nil {
+			msg := fmt.Sprintf("invalid advertisement cid: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		next, _, err = s.e.GetLatestAdv(r.Context())
+		if err != nil {
+			msg := fmt.Sprintf("failed to get latest advertisement: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := &ListAdvsRes{
+		Advs: []AdvRes{},
+	}
+	for next != cid.Undef && len(resp.Advs) < limit {
+		ad, err := s.e.GetAdv(r.Context(), next)
+		if err != nil {
+			// Only the advertisement to list from may be missing, since the chain is stored in full.
+			if len(resp.Advs) == 0 && errors.Is(err, datastore.ErrNotFound) {
+				msg := fmt.Sprintf("advertisement not found: %s", next)
+				log.Info(msg)
+				http.Error(w, msg, http.StatusNotFound)
+				return
+			}
+			msg := fmt.Sprintf("failed to get advertisement %s: %v", next, err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		adv := toAdvRes(next, ad)
+		resp.Advs = append(resp.Advs, *adv)
+		next = adv.PreviousAdvId
+	}
+	resp.Next = next
+
+	log.Infow("Listed advertisements successfully", "count", len(resp.Advs), "next", resp.Next)
+	respond(w, http.StatusOK, resp)
+}
+
+func toAdvRes(adCid cid.Cid, ad *schema.Advertisement) *AdvRes {
+	res := &AdvRes{
+		AdvId:     adCid,
+		Provider:  ad.Provider,
+		Addresses: ad.Addresses,
+		ContextID: ad.ContextID,
+		Metadata:  ad.Metadata,
+		Protocols: []string{},
+		IsRm:      ad.IsRm,
+	}
+	if ad.PreviousID != nil {
+		res.PreviousAdvId = ad.PreviousID.(cidlink.Link).Cid
+	}
+	if ad.Entries != nil {
+		res.Entries = ad.Entries.(cidlink.Link).Cid
+	}
+	var md metadata.Metadata
+	if err := md.UnmarshalBinary(ad.Metadata); err != nil {
+		log.Warnw("Failed to decode advertisement metadata", "cid", adCid, "err", err)
+	} else {
+		for _, p := range md.Protocols() {
+			res.Protocols = append(res.Protocols, p.String())
+		}
+	}
+	return res
+}
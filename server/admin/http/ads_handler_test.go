@@ -0,0 +1,106 @@
+package adminserver
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_advsHandlers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, eng.Shutdown()) })
+
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.l.Close() })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.server.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// No advertisements are published yet.
+	require.Equal(t, http.StatusNotFound, get("/admin/ads/latest").Code)
+	rr := get("/admin/ads")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var listRes ListAdvsRes
+	_, err = listRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Empty(t, listRes.Advs)
+	require.Equal(t, cid.Undef, listRes.Next)
+
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+	md := metadata.New(metadata.Bitswap{})
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := eng.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+
+	rr = get("/admin/ads/latest")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var advRes AdvRes
+	_, err = advRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, adCids[2], advRes.AdvId)
+	require.Equal(t, adCids[1], advRes.PreviousAdvId)
+	require.Equal(t, []byte("crab"), advRes.ContextID)
+	require.Equal(t, []string{"transport-bitswap"}, advRes.Protocols)
+	require.False(t, advRes.IsRm)
+
+	rr = get("/admin/ads/" + adCids[0].String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	advRes = AdvRes{}
+	_, err = advRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, adCids[0], advRes.AdvId)
+	require.Equal(t, cid.Undef, advRes.PreviousAdvId)
+	require.Equal(t, []byte("fish"), advRes.ContextID)
+
+	require.Equal(t, http.StatusBadRequest, get("/admin/ads/fish").Code)
+	require.Equal(t, http.StatusNotFound, get("/admin/ads/"+testutil.RandomCids(t, rng, 1)[0].String()).Code)
+
+	// Walk the chain two advertisements at a time.
+	rr = get("/admin/ads?limit=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	listRes = ListAdvsRes{}
+	_, err = listRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, listRes.Advs, 2)
+	require.Equal(t, adCids[2], listRes.Advs[0].AdvId)
+	require.Equal(t, adCids[1], listRes.Advs[1].AdvId)
+	require.Equal(t, adCids[0], listRes.Next)
+
+	rr = get("/admin/ads?limit=2&from=" + listRes.Next.String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	listRes = ListAdvsRes{}
+	_, err = listRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, listRes.Advs, 1)
+	require.Equal(t, adCids[0], listRes.Advs[0].AdvId)
+	require.Equal(t, cid.Undef, listRes.Next)
+
+	require.Equal(t, http.StatusBadRequest, get("/admin/ads?limit=0").Code)
+	require.Equal(t, http.StatusBadRequest, get("/admin/ads?from=fish").Code)
+}
@@ -0,0 +1,34 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) announceRetriesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received announce retries request")
+
+	retries, err := s.e.AnnounceRetries(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to get announce retries: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &AnnounceRetriesRes{
+		Retries: make([]AnnounceRetry, 0, len(retries)),
+	}
+	for _, retry := range retries {
+		resp.Retries = append(resp.Retries, AnnounceRetry{
+			URL:           retry.URL,
+			AdvId:         retry.AdCid,
+			Attempts:      retry.Attempts,
+			FirstFailedAt: retry.FirstFailedAt,
+			NextAttemptAt: retry.NextAttemptAt,
+			LastErr:       retry.LastErr,
+		})
+	}
+	log.Infow("Retrieved announce retries successfully", "count", len(resp.Retries))
+	respond(w, http.StatusOK, resp)
+}
@@ -0,0 +1,108 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func (s *Server) listAnnounceURLsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received list announce URLs request")
+	respond(w, http.StatusOK, toAnnounceURLsRes(s.e.AnnounceURLs()))
+}
+
+func (s *Server) addAnnounceURLHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received add announce URL request")
+	u, ok := readAnnounceURLReq(w, r)
+	if !ok {
+		return
+	}
+	log := log.With("url", u)
+
+	s.announceURLsLk.Lock()
+	defer s.announceURLsLk.Unlock()
+	if !s.e.AddAnnounceURL(u) {
+		log.Info("Announce URL already exists")
+	} else if !s.persistAnnounceURLs(w) {
+		return
+	}
+	log.Info("Added announce URL successfully")
+	respond(w, http.StatusOK, toAnnounceURLsRes(s.e.AnnounceURLs()))
+}
+
+func (s *Server) removeAnnounceURLHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received remove announce URL request")
+	u, ok := readAnnounceURLReq(w, r)
+	if !ok {
+		return
+	}
+	log := log.With("url", u)
+
+	s.announceURLsLk.Lock()
+	defer s.announceURLsLk.Unlock()
+	removed, err := s.e.RemoveAnnounceURL(r.Context(), u)
+	if err != nil {
+		msg := fmt.Sprintf("failed to remove announce URL: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		log.Info("Announce URL not found")
+		http.Error(w, "announce URL not found", http.StatusNotFound)
+		return
+	}
+	if !s.persistAnnounceURLs(w) {
+		return
+	}
+	log.Info("Removed announce URL successfully")
+	respond(w, http.StatusOK, toAnnounceURLsRes(s.e.AnnounceURLs()))
+}
+
+func readAnnounceURLReq(w http.ResponseWriter, r *http.Request) (*url.URL, bool) {
+	var req AnnounceURLReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return nil, false
+	}
+	if req.URL == "" {
+		http.Error(w, "missing url in request", http.StatusBadRequest)
+		return nil, false
+	}
+	u, err := url.Parse(req.URL)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = fmt.Errorf("url must be absolute")
+	}
+	if err != nil {
+		msg := fmt.Sprintf("invalid url: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return nil, false
+	}
+	return u, true
+}
+
+// persistAnnounceURLs notifies the change of announce URLs, if configured to do so, and writes an
+// error response if that fails. The announceURLsLk must be held by the caller.
+func (s *Server) persistAnnounceURLs(w http.ResponseWriter) bool {
+	if s.onAnnounceURLsChange == nil {
+		return true
+	}
+	if err := s.onAnnounceURLsChange(s.e.AnnounceURLs()); err != nil {
+		msg := fmt.Sprintf("announce URLs changed but could not be persisted: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func toAnnounceURLsRes(urls []*url.URL) *AnnounceURLsRes {
+	resp := &AnnounceURLsRes{URLs: make([]string, 0, len(urls))}
+	for _, u := range urls {
+		resp.URLs = append(resp.URLs, u.String())
+	}
+	return resp
+}
@@ -0,0 +1,83 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_addAnnounceURLHandlerPersistsLatestURLs(t *testing.T) {
+	ctx := context.Background()
+
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, eng.Shutdown()) })
+
+	var persistLk sync.Mutex
+	var persisted []string
+	var calls int
+	firstEntered := make(chan struct{})
+	secondPersisted := make(chan struct{})
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"),
+		WithOnAnnounceURLsChange(func(urls []*url.URL) error {
+			persistLk.Lock()
+			calls++
+			call := calls
+			persistLk.Unlock()
+			if call == 1 {
+				// Give a concurrent change the chance to be persisted before the first one.
+				close(firstEntered)
+				select {
+				case <-secondPersisted:
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			persistLk.Lock()
+			persisted = toAnnounceURLsRes(urls).URLs
+			persistLk.Unlock()
+			if call == 2 {
+				close(secondPersisted)
+			}
+			return nil
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.l.Close() })
+
+	add := func(u string) {
+		var body bytes.Buffer
+		req := AnnounceURLReq{URL: u}
+		_, err := req.WriteTo(&body)
+		require.NoError(t, err)
+		r, err := http.NewRequest(http.MethodPost, "/admin/announce/urls/add", &body)
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		subject.server.Handler.ServeHTTP(rr, r)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	fish := "http://fish.example"
+	lobster := "http://lobster.example"
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		add(fish)
+	}()
+	<-firstEntered
+	add(lobster)
+	wg.Wait()
+
+	want := []string{fish, lobster}
+	require.ElementsMatch(t, want, persisted)
+	require.ElementsMatch(t, want, toAnnounceURLsRes(eng.AnnounceURLs()).URLs)
+}
@@ -0,0 +1,25 @@
+package adminserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearerAuth is a middleware that rejects requests that do not present the given bearer token in
+// their Authorization header.
+func bearerAuth(token string) func(http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := []byte(strings.TrimSpace(r.Header.Get("Authorization")))
+			if subtle.ConstantTimeCompare(got, want) != 1 {
+				log.Warnw("Rejected unauthorized request", "path", r.URL.Path, "remote", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
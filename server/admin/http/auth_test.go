@@ -0,0 +1,40 @@
+package adminserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_bearerAuth(t *testing.T) {
+	handler := bearerAuth("fish")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer lobster", http.StatusUnauthorized},
+		{"wrong scheme", "Basic fish", http.StatusUnauthorized},
+		{"matching token", "Bearer fish", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/policy", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				require.Contains(t, rr.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+)
+
+func (s *Server) compactChainHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received compact chain request")
+
+	head, count, err := s.e.Compact(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrContextIDNotFound):
+			msg := "no advertised content to compact"
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+		case errors.Is(err, engine.ErrChainChanged):
+			msg := fmt.Sprintf("failed to compact chain: %v; retry", err)
+			log.Warn(msg)
+			http.Error(w, msg, http.StatusConflict)
+		default:
+			msg := fmt.Sprintf("failed to compact chain: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Infow("Compacted advertisement chain successfully", "head", head, "count", count)
+
+	// Respond with successful compaction result.
+	resp := &CompactChainRes{AdvId: head, Count: count}
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) verifyChainHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received verify chain request")
+
+	report, err := s.e.VerifyChain(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to verify chain: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Verified advertisement chain successfully", "head", report.Head, "problems", len(report.Problems))
+
+	// Respond with the verification report; problems with the chain are not a failure of the request.
+	resp := &VerifyChainRes{
+		Head:           report.Head,
+		Advertisements: report.Advertisements,
+		Mappings:       report.Mappings,
+		Problems:       []ChainProblem{},
+	}
+	for _, problem := range report.Problems {
+		cp := ChainProblem{
+			AdvId:     problem.AdCid,
+			ContextID: problem.ContextID,
+			Problem:   problem.Err.Error(),
+		}
+		if problem.Provider != "" {
+			cp.Provider = problem.Provider.String()
+		}
+		resp.Problems = append(resp.Problems, cp)
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) exportChainHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received export chain request")
+
+	// Decode request.
+	var req ExportChainReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.Out == "" {
+		msg := "output path must be specified"
+		log.Error(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	head, count, err := s.e.ExportChain(r.Context(), req.Out, req.WithEntries)
+	if err != nil {
+		if errors.Is(err, engine.ErrNoAdvertisements) {
+			msg := "no advertisements to export"
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to export chain: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Exported advertisement chain successfully", "head", head, "count", count, "out", req.Out)
+	resp := &ChainCarRes{AdvId: head, Count: count}
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) importChainHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received import chain request")
+
+	// Decode request.
+	var req ImportChainReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.In == "" {
+		msg := "input path must be specified"
+		log.Error(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	head, count, err := s.e.ImportChain(r.Context(), req.In)
+	if err != nil {
+		if errors.Is(err, engine.ErrChainExists) || errors.Is(err, engine.ErrChainImporting) {
+			msg := fmt.Sprintf("failed to import chain: %v", err)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, engine.ErrChainSignerMismatch) {
+			msg := fmt.Sprintf("failed to import chain: %v", err)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		msg := fmt.Sprintf("failed to import chain: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Imported advertisement chain successfully", "head", head, "count", count, "in", req.In)
+	resp := &ChainCarRes{AdvId: head, Count: count}
+	respond(w, http.StatusOK, resp)
+}
@@ -0,0 +1,159 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	// defaultListContextIDsLimit is the number of context IDs listed per page when no limit is
+	// specified.
+	defaultListContextIDsLimit = 1000
+	// maxListContextIDsLimit is the maximum number of context IDs listed per page.
+	maxListContextIDsLimit = 10000
+)
+
+// contextIDStateHandler returns the state of the base64 encoded context ID specified by the query
+// parameter "id", for the provider specified by the optional query parameter "provider".
+func (s *Server) contextIDStateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received context ID state request")
+
+	query := r.URL.Query()
+	contextID, err := base64.StdEncoding.DecodeString(query.Get("id"))
+	if err != nil || len(contextID) == 0 {
+		msg := "context ID must be specified as non-empty base64 encoded bytes"
+		log.Errorw(msg, "id", query.Get("id"))
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	var p peer.ID
+	if v := query.Get("provider"); v != "" {
+		p, err = peer.Decode(v)
+		if err != nil {
+			msg := fmt.Sprintf("invalid provider id: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	state, err := s.e.GetContextState(r.Context(), p, contextID)
+	if err != nil {
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			msg := "context ID not found"
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to get context ID state: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &ContextIDStateRes{
+		Provider:   state.Provider.String(),
+		ContextID:  state.ContextID,
+		EntriesCid: state.EntriesCid,
+		Protocols:  []string{},
+		IsRm:       state.IsRm,
+		LastAdvId:  state.LastAdCid,
+	}
+	if state.Metadata.Len() != 0 {
+		resp.Metadata, err = state.Metadata.MarshalBinary()
+		if err != nil {
+			msg := fmt.Sprintf("failed to encode metadata: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		for _, proto := range state.Metadata.Protocols() {
+			resp.Protocols = append(resp.Protocols, proto.String())
+		}
+	}
+	log.Infow("Retrieved context ID state successfully", "provider", state.Provider, "lastAdvId", state.LastAdCid)
+	respond(w, http.StatusOK, resp)
+}
+
+// listContextIDsHandler lists the context IDs currently advertised for the provider specified by
+// the optional query parameter "provider", resuming after the cursor specified by the optional query
+// parameter "from" and listing at most as many as the optional query parameter "limit".
+func (s *Server) listContextIDsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received list context IDs request")
+
+	query := r.URL.Query()
+	var p peer.ID
+	if v := query.Get("provider"); v != "" {
+		var err error
+		p, err = peer.Decode(v)
+		if err != nil {
+			msg := fmt.Sprintf("invalid provider id: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultListContextIDsLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListContextIDsLimit {
+			msg := fmt.Sprintf("limit must be an integer between 1 and %d", maxListContextIDsLimit)
+			log.Errorw(msg, "limit", v)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	iter, err := s.e.ListContextIDsFrom(r.Context(), p, query.Get("from"))
+	if err != nil {
+		if errors.Is(err, engine.ErrInvalidCursor) {
+			msg := "from must be a cursor returned as next by a previous listing"
+			log.Errorw(msg, "from", query.Get("from"))
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		msg := fmt.Sprintf("failed to list context IDs: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	defer iter.Close()
+
+	resp := &ListContextIDsRes{
+		ContextIDs: []AdvertisedContextID{},
+	}
+	// Read one context ID beyond the page to determine whether there are more.
+	for i := 0; i <= limit; i++ {
+		cursor := iter.Cursor()
+		contextID, entriesCid, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			msg := fmt.Sprintf("failed to list context IDs: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		if i == limit {
+			resp.Next = cursor
+			break
+		}
+		resp.ContextIDs = append(resp.ContextIDs, AdvertisedContextID{
+			ContextID:  contextID,
+			EntriesCid: entriesCid,
+		})
+	}
+
+	log.Infow("Listed context IDs successfully", "count", len(resp.ContextIDs), "next", resp.Next)
+	respond(w, http.StatusOK, resp)
+}
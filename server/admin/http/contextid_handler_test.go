@@ -0,0 +1,135 @@
+package adminserver
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_listContextIDsHandler(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, eng.Shutdown()) })
+
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.l.Close() })
+
+	list := func(query string) ListContextIDsRes {
+		req, err := http.NewRequest(http.MethodGet, "/admin/contextids"+query, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.server.Handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var res ListContextIDsRes
+		_, err = res.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := list("")
+	require.Empty(t, res.ContextIDs)
+	require.Empty(t, res.Next)
+
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+	md := metadata.New(metadata.Bitswap{})
+	for _, contextID := range []string{"lobster", "fish", "crab"} {
+		_, err := eng.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+
+	var got []string
+	for from := ""; ; {
+		res = list("?limit=2&from=" + url.QueryEscape(from))
+		for _, c := range res.ContextIDs {
+			got = append(got, string(c.ContextID))
+		}
+		if res.Next == "" {
+			break
+		}
+		from = res.Next
+	}
+	require.Equal(t, []string{"crab", "fish", "lobster"}, got)
+
+	// Context IDs removed before the cursor do not shift the next page.
+	res = list("?limit=1")
+	require.Equal(t, "crab", string(res.ContextIDs[0].ContextID))
+	_, err = eng.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	res = list("?limit=1&from=" + url.QueryEscape(res.Next))
+	require.Equal(t, "fish", string(res.ContextIDs[0].ContextID))
+
+	req, err := http.NewRequest(http.MethodGet, "/admin/contextids?from=fish", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	subject.server.Handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	res = list("?limit=2")
+	require.Len(t, res.ContextIDs, 2)
+	require.Empty(t, res.Next)
+}
+
+func Test_listContextIDsHandlerPagesBinaryContextIDs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	ctx := context.Background()
+
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, eng.Shutdown()) })
+
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.l.Close() })
+
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(testutil.RandomMultihashes(t, rng, 5)), nil
+	})
+	md := metadata.New(metadata.Bitswap{})
+	// Context IDs that are not valid UTF-8, and that would be indistinguishable if the invalid
+	// bytes were replaced with U+FFFD.
+	want := []string{"\xfe\x01", "\xfe\x02", "\xff\x01", "\xff\x02"}
+	for _, contextID := range want {
+		_, err := eng.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+
+	var got []string
+	for from := ""; ; {
+		// Guard against a cursor that resumes at a previous position.
+		require.Less(t, len(got), len(want), "listed more context IDs than advertised")
+		req, err := http.NewRequest(http.MethodGet, "/admin/contextids?limit=1&from="+url.QueryEscape(from), nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.server.Handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var res ListContextIDsRes
+		_, err = res.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		for _, c := range res.ContextIDs {
+			got = append(got, string(c.ContextID))
+		}
+		if res.Next == "" {
+			break
+		}
+		from = res.Next
+	}
+	require.Equal(t, want, got)
+}
@@ -0,0 +1,45 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/index-provider/engine"
+)
+
+func (s *Server) gcHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received garbage collection request")
+
+	// Decode request.
+	var req GCReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	stats, err := s.e.GC(r.Context(),
+		engine.WithGCDryRun(req.DryRun),
+		engine.WithGCProgress(func(progress engine.GCStats) {
+			log.Infow("Garbage collection in progress", "sweptAds", progress.SweptAds, "sweptEntries", progress.SweptEntries)
+		}))
+	if err != nil {
+		msg := fmt.Sprintf("failed to collect garbage: %v", err)
+		log.Errorw(msg, "err", err, "sweptAds", stats.SweptAds, "sweptEntries", stats.SweptEntries)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Collected garbage successfully", "dryRun", req.DryRun, "sweptAds", stats.SweptAds, "sweptEntries", stats.SweptEntries)
+
+	// Respond with successful garbage collection result.
+	resp := &GCRes{
+		DryRun:       stats.DryRun,
+		LiveAds:      stats.LiveAds,
+		LiveEntries:  stats.LiveEntries,
+		SweptAds:     stats.SweptAds,
+		SweptEntries: stats.SweptEntries,
+	}
+	respond(w, http.StatusOK, resp)
+}
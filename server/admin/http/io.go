@@ -12,15 +12,53 @@ var (
 	_ io.ReaderFrom = (*ImportCarRes)(nil)
 	_ io.ReaderFrom = (*RemoveCarReq)(nil)
 	_ io.ReaderFrom = (*RemoveCarRes)(nil)
+	_ io.ReaderFrom = (*RemoveProviderReq)(nil)
+	_ io.ReaderFrom = (*RemoveProviderRes)(nil)
 	_ io.ReaderFrom = (*ConnectReq)(nil)
 	_ io.ReaderFrom = (*ConnectRes)(nil)
+	_ io.ReaderFrom = (*CompactChainRes)(nil)
+	_ io.ReaderFrom = (*GCReq)(nil)
+	_ io.ReaderFrom = (*GCRes)(nil)
+	_ io.ReaderFrom = (*SyncStatusRes)(nil)
+	_ io.ReaderFrom = (*AnnounceRetriesRes)(nil)
+	_ io.ReaderFrom = (*AnnounceURLReq)(nil)
+	_ io.ReaderFrom = (*AnnounceURLsRes)(nil)
+	_ io.ReaderFrom = (*PolicyReq)(nil)
+	_ io.ReaderFrom = (*PolicyRes)(nil)
+	_ io.ReaderFrom = (*AdvRes)(nil)
+	_ io.ReaderFrom = (*ListAdvsRes)(nil)
+	_ io.ReaderFrom = (*ContextIDStateRes)(nil)
+	_ io.ReaderFrom = (*ListContextIDsRes)(nil)
+	_ io.ReaderFrom = (*VerifyChainRes)(nil)
+	_ io.ReaderFrom = (*ExportChainReq)(nil)
+	_ io.ReaderFrom = (*ImportChainReq)(nil)
+	_ io.ReaderFrom = (*ChainCarRes)(nil)
 
 	_ io.WriterTo = (*ImportCarReq)(nil)
 	_ io.WriterTo = (*ImportCarRes)(nil)
 	_ io.WriterTo = (*RemoveCarReq)(nil)
 	_ io.WriterTo = (*RemoveCarRes)(nil)
+	_ io.WriterTo = (*RemoveProviderReq)(nil)
+	_ io.WriterTo = (*RemoveProviderRes)(nil)
 	_ io.WriterTo = (*ConnectReq)(nil)
 	_ io.WriterTo = (*ConnectRes)(nil)
+	_ io.WriterTo = (*CompactChainRes)(nil)
+	_ io.WriterTo = (*GCReq)(nil)
+	_ io.WriterTo = (*GCRes)(nil)
+	_ io.WriterTo = (*SyncStatusRes)(nil)
+	_ io.WriterTo = (*AnnounceRetriesRes)(nil)
+	_ io.WriterTo = (*AnnounceURLReq)(nil)
+	_ io.WriterTo = (*AnnounceURLsRes)(nil)
+	_ io.WriterTo = (*PolicyReq)(nil)
+	_ io.WriterTo = (*PolicyRes)(nil)
+	_ io.WriterTo = (*AdvRes)(nil)
+	_ io.WriterTo = (*ListAdvsRes)(nil)
+	_ io.WriterTo = (*ContextIDStateRes)(nil)
+	_ io.WriterTo = (*ListContextIDsRes)(nil)
+	_ io.WriterTo = (*VerifyChainRes)(nil)
+	_ io.WriterTo = (*ExportChainReq)(nil)
+	_ io.WriterTo = (*ImportChainReq)(nil)
+	_ io.WriterTo = (*ChainCarRes)(nil)
 )
 
 func (er *ImportCarReq) WriteTo(w io.Writer) (int64, error) {
@@ -55,6 +93,22 @@ func (er *RemoveCarRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *RemoveProviderReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveProviderReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveProviderRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveProviderRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func (er *ListCarRes) WriteTo(w io.Writer) (int64, error) {
 	return marshalToJson(w, er)
 }
@@ -87,6 +141,142 @@ func (er *AnnounceRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *CompactChainRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *CompactChainRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *GCReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *GCReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *GCRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *GCRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *SyncStatusRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *SyncStatusRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AnnounceRetriesRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AnnounceRetriesRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AnnounceURLReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AnnounceURLReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AnnounceURLsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AnnounceURLsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *PolicyReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *PolicyReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *PolicyRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *PolicyRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AdvRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AdvRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListAdvsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListAdvsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ContextIDStateRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ContextIDStateRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListContextIDsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListContextIDsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *VerifyChainRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *VerifyChainRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ExportChainReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ExportChainReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ImportChainReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ImportChainReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ChainCarRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ChainCarRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func respond(w http.ResponseWriter, statusCode int, body io.WriterTo) {
 	w.WriteHeader(statusCode)
 	// Attempt to serialize body as JSON
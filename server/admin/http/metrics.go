@@ -0,0 +1,34 @@
+package adminserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/filecoin-project/index-provider/metrics"
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// countRequests is a middleware that counts the requests handled by the admin server per route
+// and response status code.
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		var route string
+		if cr := mux.CurrentRoute(r); cr != nil {
+			route, _ = cr.GetPathTemplate()
+		}
+		metrics.AdminRequests.WithLabelValues(route, strconv.Itoa(rec.status)).Inc()
+	})
+}
@@ -1,6 +1,8 @@
 package adminserver
 
 import (
+	"time"
+
 	"github.com/ipfs/go-cid"
 )
 
@@ -46,6 +48,19 @@ type (
 	}
 )
 
+type (
+	// RemoveProviderReq represents a request for removing all content advertised for a provider.
+	RemoveProviderReq struct {
+		// The peer ID of the provider to remove. If empty, the default provider is assumed.
+		Provider string `json:"provider"`
+	}
+	// RemoveProviderRes represents the response to a RemoveProviderReq.
+	RemoveProviderRes struct {
+		// The CIDs of the removal advertisements generated, in order of publication.
+		AdvIds []cid.Cid `json:"adv_ids"`
+	}
+)
+
 type (
 	// ListCarRes represents the response to list cars.
 	ListCarRes struct {
@@ -60,3 +75,226 @@ type (
 		AdvId cid.Cid `json:"adv_id"`
 	}
 )
+
+type (
+	// CompactChainRes represents the response to a request for compacting the advertisement chain.
+	CompactChainRes struct {
+		// The CID of the head of the compacted advertisement chain.
+		AdvId cid.Cid `json:"adv_id"`
+		// The number of advertisements in the compacted chain.
+		Count int `json:"count"`
+	}
+
+	// VerifyChainRes represents the response to a request for verifying the advertisement chain.
+	VerifyChainRes struct {
+		// The CID of the advertisement from which the chain was verified, or null if no
+		// advertisements are published.
+		Head cid.Cid `json:"head"`
+		// The number of advertisements verified.
+		Advertisements int `json:"advertisements"`
+		// The number of context ID to entries mappings verified.
+		Mappings int `json:"mappings"`
+		// The problems found, if any.
+		Problems []ChainProblem `json:"problems"`
+	}
+	// ExportChainReq represents a request for exporting the advertisement chain as a CAR file.
+	ExportChainReq struct {
+		// The path at which to write the CAR file, local to the provider. The file must not exist.
+		Out string `json:"out"`
+		// Whether to include the entries of the context IDs currently advertised.
+		WithEntries bool `json:"with_entries"`
+	}
+	// ImportChainReq represents a request for importing the advertisement chain from a CAR file.
+	ImportChainReq struct {
+		// The path to the CAR file, local to the provider.
+		In string `json:"in"`
+	}
+	// ChainCarRes represents the response to an ExportChainReq or ImportChainReq.
+	ChainCarRes struct {
+		// The CID of the latest advertisement in the chain.
+		AdvId cid.Cid `json:"adv_id"`
+		// The number of advertisements exported or imported.
+		Count int `json:"count"`
+	}
+	// ChainProblem represents a problem found while verifying the advertisement chain.
+	ChainProblem struct {
+		// The CID of the advertisement the problem was found at, or null if the problem is with a
+		// context ID mapping.
+		AdvId cid.Cid `json:"adv_id"`
+		// The peer ID of the provider the problem relates to, if known.
+		Provider string `json:"provider"`
+		// The context ID the problem relates to, if any.
+		ContextID []byte `json:"context_id"`
+		// The description of the problem.
+		Problem string `json:"problem"`
+	}
+)
+
+type (
+	// GCReq represents a request for collecting the garbage in the provider datastore.
+	GCReq struct {
+		// Whether to only count the garbage without deleting it.
+		DryRun bool `json:"dry_run"`
+	}
+	// GCRes represents the response to a GCReq.
+	GCRes struct {
+		// Whether the garbage was only counted without being deleted.
+		DryRun bool `json:"dry_run"`
+		// The number of reachable advertisements.
+		LiveAds int `json:"live_ads"`
+		// The number of entries referenced by context IDs currently advertised.
+		LiveEntries int `json:"live_entries"`
+		// The number of unreachable advertisements collected.
+		SweptAds int `json:"swept_ads"`
+		// The number of unreferenced cached entries collected.
+		SweptEntries int `json:"swept_entries"`
+	}
+)
+
+type (
+	// SyncStatusRes represents the response to a request for the sync status of indexers.
+	SyncStatusRes struct {
+		// The CID of the latest advertisement.
+		LatestAdvId cid.Cid `json:"latest_adv_id"`
+		// The sync status of indexers that have synced from the provider.
+		Indexers []IndexerSyncStatus `json:"indexers"`
+	}
+	// IndexerSyncStatus represents the sync status of an indexer.
+	IndexerSyncStatus struct {
+		// The peer ID of the indexer, or empty for anonymous syncs over HTTP.
+		Peer string `json:"peer"`
+		// The CID of the latest advertisement synced by the indexer, if any.
+		LastAdvId cid.Cid `json:"last_adv_id"`
+		// The time at which the latest advertisement was synced.
+		LastAdvSyncedAt time.Time `json:"last_adv_synced_at"`
+		// The number of entries blocks served to the indexer.
+		EntriesBlocksServed int64 `json:"entries_blocks_served"`
+		// The number of advertisements published after the latest advertisement synced by the
+		// indexer, or -1 if that advertisement is not in the current chain.
+		Lag int `json:"lag"`
+	}
+
+	// AnnounceRetriesRes represents the response to a request for the direct HTTP announcements
+	// queued for retry.
+	AnnounceRetriesRes struct {
+		// The announcements queued for retry, ordered by the time they are next retried.
+		Retries []AnnounceRetry `json:"retries"`
+	}
+	// AnnounceRetry represents a failed direct HTTP announcement queued for retry.
+	AnnounceRetry struct {
+		// The URL of the indexer to announce to.
+		URL string `json:"url"`
+		// The CID of the advertisement to announce.
+		AdvId cid.Cid `json:"adv_id"`
+		// The number of failed attempts so far.
+		Attempts int `json:"attempts"`
+		// The time at which announcing to the indexer first failed.
+		FirstFailedAt time.Time `json:"first_failed_at"`
+		// The time after which the announcement is next retried.
+		NextAttemptAt time.Time `json:"next_attempt_at"`
+		// The error that occurred during the last attempt.
+		LastErr string `json:"last_err"`
+	}
+)
+
+type (
+	// AnnounceURLReq represents a request to add or remove an indexer URL to which direct HTTP
+	// announcements are sent.
+	AnnounceURLReq struct {
+		// The indexer URL.
+		URL string `json:"url"`
+	}
+	// AnnounceURLsRes represents the indexer URLs to which direct HTTP announcements are sent.
+	AnnounceURLsRes struct {
+		// The indexer URLs.
+		URLs []string `json:"urls"`
+	}
+)
+
+type (
+	// PolicyReq represents a request to allow or block a peer from syncing advertisements.
+	PolicyReq struct {
+		// The peer ID to allow or block.
+		Peer string `json:"peer"`
+		// Whether to allow, or otherwise block, the peer.
+		Allow bool `json:"allow"`
+	}
+	// PolicyRes represents the sync policy.
+	PolicyRes struct {
+		// Whether peers are allowed to sync by default.
+		Allow bool `json:"allow"`
+		// The peer IDs that are exceptions to the default.
+		Except []string `json:"except"`
+	}
+)
+
+type (
+	// AdvRes represents a decoded advertisement.
+	AdvRes struct {
+		// The CID of the advertisement.
+		AdvId cid.Cid `json:"adv_id"`
+		// The CID of the previous advertisement in the chain, or null if this is the first.
+		PreviousAdvId cid.Cid `json:"previous_adv_id"`
+		// The peer ID of the provider the advertisement is for.
+		Provider string `json:"provider"`
+		// The multiaddrs at which the content is retrievable.
+		Addresses []string `json:"addresses"`
+		// The CID of the root of the advertisement entries.
+		Entries cid.Cid `json:"entries"`
+		// The context ID of the advertisement.
+		ContextID []byte `json:"context_id"`
+		// The encoded metadata of the advertisement.
+		Metadata []byte `json:"metadata"`
+		// The names of the transport protocols in the metadata, or empty if the metadata cannot be
+		// decoded.
+		Protocols []string `json:"protocols"`
+		// Whether the advertisement is a removal advertisement.
+		IsRm bool `json:"is_rm"`
+	}
+	// ListAdvsRes represents a page of the advertisement chain, walked from the latest towards the
+	// earliest advertisement.
+	ListAdvsRes struct {
+		// The advertisements, in chain order.
+		Advs []AdvRes `json:"advs"`
+		// The CID from which to list the next page, or null if the end of the chain is reached.
+		Next cid.Cid `json:"next"`
+	}
+)
+
+type (
+	// ContextIDStateRes represents the state of a context ID.
+	ContextIDStateRes struct {
+		// The peer ID of the provider the context ID belongs to.
+		Provider string `json:"provider"`
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// The CID of the root of the entries advertised for the context ID, or null if removed.
+		EntriesCid cid.Cid `json:"entries_cid"`
+		// The encoded current metadata of the context ID, or empty if removed.
+		Metadata []byte `json:"metadata"`
+		// The names of the transport protocols in the current metadata.
+		Protocols []string `json:"protocols"`
+		// Whether the context ID is removed.
+		IsRm bool `json:"is_rm"`
+		// The CID of the latest advertisement for the context ID, or null if not found in the chain.
+		LastAdvId cid.Cid `json:"last_adv_id"`
+	}
+)
+
+type (
+	// ListContextIDsRes represents a page of the context IDs currently advertised for a provider.
+	ListContextIDsRes struct {
+		// The context IDs, in a stable order across pages.
+		ContextIDs []AdvertisedContextID `json:"context_ids"`
+		// The cursor to pass as "from" to list the next page, or empty if there are no more context
+		// IDs.
+		Next string `json:"next"`
+	}
+	// AdvertisedContextID represents a context ID currently advertised for a provider.
+	AdvertisedContextID struct {
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// The CID of the root of the entries advertised for the context ID.
+		EntriesCid cid.Cid `json:"entries_cid"`
+	}
+)
@@ -1,6 +1,10 @@
 package adminserver
 
-import "time"
+import (
+	"crypto/tls"
+	"net/url"
+	"time"
+)
 
 type (
 	// Option captures a configurable parameter in admin HTTP server.
@@ -10,6 +14,11 @@ type (
 		listenAddr   string
 		readTimeout  time.Duration
 		writeTimeout time.Duration
+		bearerToken  string
+		tlsConfig    *tls.Config
+
+		onAnnounceURLsChange func([]*url.URL) error
+		onSyncPolicyChange   func(allow bool, except []string) error
 	}
 )
 
@@ -54,3 +63,43 @@ func WithWriteTimeout(t time.Duration) Option {
 		return nil
 	}
 }
+
+// WithBearerToken requires requests to the admin server to present the given token in their
+// Authorization header, i.e. "Bearer <token>". Requests without a matching token are rejected as
+// unauthorized. If unset or empty, requests are not authenticated.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLS serves the admin server over TLS with the given configuration. Mutual TLS is enabled by
+// setting the configuration to require and verify client certificates, e.g.
+// tls.RequireAndVerifyClientCert. If unset, the admin server is served over plain HTTP.
+func WithTLS(c *tls.Config) Option {
+	return func(o *options) error {
+		o.tlsConfig = c
+		return nil
+	}
+}
+
+// WithOnAnnounceURLsChange sets the function called with the resulting direct announce URLs
+// whenever they are changed via the admin server, e.g. in order to persist them. Calls are
+// serialized with the changes, in the order the changes are made.
+func WithOnAnnounceURLsChange(fn func([]*url.URL) error) Option {
+	return func(o *options) error {
+		o.onAnnounceURLsChange = fn
+		return nil
+	}
+}
+
+// WithOnSyncPolicyChange sets the function called with the resulting sync policy whenever it is
+// changed via the admin server, e.g. in order to persist it. Calls are serialized with the changes,
+// in the order the changes are made.
+func WithOnSyncPolicyChange(fn func(allow bool, except []string) error) Option {
+	return func(o *options) error {
+		o.onSyncPolicyChange = fn
+		return nil
+	}
+}
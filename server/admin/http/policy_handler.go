@@ -0,0 +1,61 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func (s *Server) getPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received get sync policy request")
+	allow, except := s.e.SyncPolicy().ToConfig()
+	respond(w, http.StatusOK, toPolicyRes(allow, except))
+}
+
+func (s *Server) setPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received set sync policy request")
+	var req PolicyReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	p, err := peer.Decode(req.Peer)
+	if err != nil {
+		msg := fmt.Sprintf("invalid peer ID: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	log := log.With("peer", p, "allow", req.Allow)
+
+	s.policyLk.Lock()
+	defer s.policyLk.Unlock()
+	pol := s.e.SyncPolicy()
+	var changed bool
+	if req.Allow {
+		changed = pol.Allow(p)
+	} else {
+		changed = pol.Block(p)
+	}
+	allow, except := pol.ToConfig()
+	if changed && s.onSyncPolicyChange != nil {
+		if err := s.onSyncPolicyChange(allow, except); err != nil {
+			msg := fmt.Sprintf("sync policy changed but could not be persisted: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Infow("Set sync policy successfully", "changed", changed)
+	respond(w, http.StatusOK, toPolicyRes(allow, except))
+}
+
+func toPolicyRes(allow bool, except []string) *PolicyRes {
+	if except == nil {
+		except = []string{}
+	}
+	return &PolicyRes{Allow: allow, Except: except}
+}
@@ -0,0 +1,88 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/index-provider/engine"
+	"github.com/filecoin-project/index-provider/engine/policy"
+	"github.com/filecoin-project/index-provider/testutil"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_setPolicyHandlerPersistsLatestPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	pol, err := policy.New(false, nil)
+	require.NoError(t, err)
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithSyncPolicy(pol))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, eng.Shutdown()) })
+
+	var persistLk sync.Mutex
+	var persisted []string
+	var calls int
+	firstEntered := make(chan struct{})
+	secondPersisted := make(chan struct{})
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"),
+		WithOnSyncPolicyChange(func(allow bool, except []string) error {
+			persistLk.Lock()
+			calls++
+			call := calls
+			persistLk.Unlock()
+			if call == 1 {
+				// Give a concurrent change the chance to be persisted before the first one.
+				close(firstEntered)
+				select {
+				case <-secondPersisted:
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			persistLk.Lock()
+			persisted = except
+			persistLk.Unlock()
+			if call == 2 {
+				close(secondPersisted)
+			}
+			return nil
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.l.Close() })
+
+	allow := func(p peer.ID) {
+		var body bytes.Buffer
+		req := PolicyReq{Peer: p.String(), Allow: true}
+		_, err := req.WriteTo(&body)
+		require.NoError(t, err)
+		r, err := http.NewRequest(http.MethodPost, "/admin/policy", &body)
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		subject.server.Handler.ServeHTTP(rr, r)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	fish := testutil.NewID(t)
+	lobster := testutil.NewID(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		allow(fish)
+	}()
+	<-firstEntered
+	allow(lobster)
+	wg.Wait()
+
+	want := []string{fish.String(), lobster.String()}
+	require.ElementsMatch(t, want, persisted)
+	_, gotExcept := pol.ToConfig()
+	require.ElementsMatch(t, want, gotExcept)
+}
@@ -0,0 +1,55 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	provider "github.com/filecoin-project/index-provider"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func (s *Server) removeProviderHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received remove provider request")
+
+	// Decode request.
+	var req RemoveProviderReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var providerID peer.ID
+	if req.Provider != "" {
+		var err error
+		providerID, err = peer.Decode(req.Provider)
+		if err != nil {
+			msg := fmt.Sprintf("failed to decode provider ID: %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	advIDs, err := s.e.NotifyRemoveProvider(r.Context(), providerID)
+	if err != nil {
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			msg := fmt.Sprintf("provider has no advertised content: %s", req.Provider)
+			log.Info(msg)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+		msg := fmt.Sprintf("failed to remove provider: %v", err)
+		log.Errorw(msg, "err", err, "provider", req.Provider, "removed", len(advIDs))
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infow("Removed provider successfully", "provider", req.Provider, "removed", len(advIDs))
+
+	// Respond with successful remove result.
+	resp := &RemoveProviderRes{AdvIds: advIDs}
+	respond(w, http.StatusOK, resp)
+}
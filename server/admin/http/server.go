@@ -2,8 +2,11 @@ package adminserver
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
 
 	"github.com/filecoin-project/index-provider/engine"
 	"github.com/filecoin-project/index-provider/supplier"
@@ -19,6 +22,15 @@ type Server struct {
 	l      net.Listener
 	h      host.Host
 	e      *engine.Engine
+
+	onAnnounceURLsChange func([]*url.URL) error
+	onSyncPolicyChange   func(allow bool, except []string) error
+	// announceURLsLk serializes announce URL changes along with their persistence, so that a
+	// change is never persisted after, and so overwritten by, the snapshot of an earlier change.
+	announceURLsLk sync.Mutex
+	// policyLk serializes sync policy changes along with their persistence, so that a change is
+	// never persisted after, and so overwritten by, the snapshot of an earlier change.
+	policyLk sync.Mutex
 }
 
 func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (*Server, error) {
@@ -31,20 +43,44 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 	if err != nil {
 		return nil, err
 	}
+	if opts.tlsConfig != nil {
+		l = tls.NewListener(l, opts.tlsConfig)
+	}
 
 	r := mux.NewRouter().StrictSlash(true)
+	r.Use(countRequests)
+	if opts.bearerToken != "" {
+		r.Use(bearerAuth(opts.bearerToken))
+	}
 	server := &http.Server{
 		Handler:      r,
 		ReadTimeout:  opts.readTimeout,
 		WriteTimeout: opts.writeTimeout,
 	}
-	s := &Server{server, l, h, e}
+	s := &Server{
+		server:               server,
+		l:                    l,
+		h:                    h,
+		e:                    e,
+		onAnnounceURLsChange: opts.onAnnounceURLsChange,
+		onSyncPolicyChange:   opts.onSyncPolicyChange,
+	}
 
 	// Set protocol handlers
 	r.HandleFunc("/admin/announce", s.announceHandler).
 		Methods(http.MethodPost)
 	r.HandleFunc("/admin/announcehttp", s.announceHttpHandler).
 		Methods(http.MethodPost)
+	r.HandleFunc("/admin/announce/retries", s.announceRetriesHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/announce/urls", s.listAnnounceURLsHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/announce/urls/add", s.addAnnounceURLHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	r.HandleFunc("/admin/announce/urls/remove", s.removeAnnounceURLHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
 
 	r.HandleFunc("/admin/connect", s.connectHandler).
 		Methods(http.MethodPost).
@@ -62,6 +98,49 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 	r.HandleFunc("/admin/list/car", cHandler.handleList).
 		Methods(http.MethodGet)
 
+	r.HandleFunc("/admin/remove/provider", s.removeProviderHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/admin/chain/compact", s.compactChainHandler).
+		Methods(http.MethodPost)
+
+	r.HandleFunc("/admin/chain/verify", s.verifyChainHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/chain/export", s.exportChainHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/admin/chain/import", s.importChainHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/admin/gc", s.gcHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
+	r.HandleFunc("/admin/sync-status", s.syncStatusHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/ads", s.listAdvsHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/ads/latest", s.getLatestAdvHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/ads/{cid}", s.getAdvHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/contextid", s.contextIDStateHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/contextids", s.listContextIDsHandler).
+		Methods(http.MethodGet)
+
+	r.HandleFunc("/admin/policy", s.getPolicyHandler).
+		Methods(http.MethodGet)
+	r.HandleFunc("/admin/policy", s.setPolicyHandler).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+
 	return s, nil
 }
 
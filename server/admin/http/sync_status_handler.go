@@ -0,0 +1,45 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) syncStatusHandler(w http.ResponseWriter, r *http.Request) {
+	log.Info("Received sync status request")
+
+	latest, _, err := s.e.GetLatestAdv(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to get latest advertisement: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	statuses, err := s.e.SyncStatus(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("failed to get sync status: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &SyncStatusRes{
+		LatestAdvId: latest,
+		Indexers:    make([]IndexerSyncStatus, 0, len(statuses)),
+	}
+	for _, status := range statuses {
+		var p string
+		if status.Peer != "" {
+			p = status.Peer.String()
+		}
+		resp.Indexers = append(resp.Indexers, IndexerSyncStatus{
+			Peer:                p,
+			LastAdvId:           status.LastAdCid,
+			LastAdvSyncedAt:     status.LastAdSyncedAt,
+			EntriesBlocksServed: status.EntriesBlocksServed,
+			Lag:                 status.Lag,
+		})
+	}
+	log.Infow("Retrieved sync status successfully", "indexers", len(resp.Indexers))
+	respond(w, http.StatusOK, resp)
+}